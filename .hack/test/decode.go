@@ -5,42 +5,14 @@ import (
 	"log"
 	"os"
 
-	"github.com/mitchellh/mapstructure"
+	"github.com/blacktop/lporg/internal/database"
 	yaml "gopkg.in/yaml.v3"
 )
 
-// Config is the Launchpad config
-type Config struct {
-	Apps      Apps     `yaml:"apps" json:"apps,omitempty"`
-	Widgets   Apps     `yaml:"widgets" json:"widgets,omitempty"`
-	DockItems []string `yaml:"dock_items" json:"dock_items,omitempty"`
-}
-
-// Apps is the launchpad apps config object
-type Apps struct {
-	Pages []Page `yaml:"pages" json:"pages,omitempty"`
-}
-
-// Page is a launchpad page object
-type Page struct {
-	Number int           `yaml:"number" json:"number"`
-	Items  []interface{} `yaml:"items,omitempty" json:"items,omitempty"`
-}
-
-// AppFolder is a launchpad folder object
-type AppFolder struct {
-	Name  string       `yaml:"folder,omitempty" json:"folder,omitempty" mapstructure:"folder"`
-	Pages []FolderPage `yaml:"pages,omitempty" json:"pages,omitempty"`
-}
-
-// FolderPage is a launchpad folder page object
-type FolderPage struct {
-	Number int      `yaml:"number,omitempty" json:"number"`
-	Items  []string `yaml:"items,omitempty" json:"items,omitempty"`
-}
-
 func testYAML() {
-	var config Config
+	var config struct {
+		Apps database.Apps `yaml:"apps"`
+	}
 
 	data, err := os.ReadFile("launchpad-test.yaml")
 	if err != nil {
@@ -55,19 +27,11 @@ func testYAML() {
 
 	for _, page := range config.Apps.Pages {
 		for _, item := range page.Items {
-			switch i := item.(type) {
-			case string:
-				fmt.Println("string", i)
-			default:
-				fmt.Printf("--- t:\n%#v\n\n", item)
-				fmt.Println(i)
-				var result AppFolder
-				err = mapstructure.Decode(item, &result)
-				if err != nil {
-					log.Fatal(err)
-				}
-
-				fmt.Printf("%#v", result)
+			switch {
+			case item.App != nil:
+				fmt.Println("app", item.App)
+			case item.Folder != nil:
+				fmt.Printf("--- t:\n%#v\n\n", *item.Folder)
 			}
 		}
 	}
@@ -0,0 +1,90 @@
+/*
+Copyright © 2024 blacktop
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/apex/log"
+	"github.com/blacktop/lporg/internal/command"
+	"github.com/spf13/cobra"
+)
+
+// backupsCmd represents the backups command
+var backupsCmd = &cobra.Command{
+	Use:   "backups",
+	Short: "Manage launchpad settings backups",
+	Args:  cobra.NoArgs,
+}
+
+// backupsListCmd represents the backups list command
+var backupsListCmd = &cobra.Command{
+	Use:           "list",
+	Short:         "List launchpad settings backups",
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		if Verbose {
+			log.SetLevel(log.DebugLevel)
+		}
+
+		conf := &command.Config{
+			Cmd:      backupsCmd.Use,
+			Cloud:    UseICloud,
+			LogLevel: setLogLevel(Verbose),
+		}
+
+		if err := conf.Verify(); err != nil {
+			return err
+		}
+
+		backups, err := command.ListBackups(conf)
+		if err != nil {
+			return err
+		}
+		if len(backups) == 0 {
+			log.Warn("no backups found (run load or default with --backup to create one)")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tDATE\tCOMMAND\tAPPS\tRAW")
+		for idx := len(backups) - 1; idx >= 0; idx-- {
+			b := backups[idx]
+			raw := "no"
+			if b.Raw() {
+				raw = "yes"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", b.Name(), b.Created.Format("2006-01-02 15:04:05"), b.Command, b.Apps, raw)
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(backupsCmd)
+	backupsCmd.AddCommand(backupsListCmd)
+}
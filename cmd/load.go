@@ -23,6 +23,7 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/AlecAivazis/survey/v2/terminal"
@@ -44,14 +45,25 @@ var loadCmd = &cobra.Command{
 			log.SetLevel(log.DebugLevel)
 		}
 
-		fmt.Println(command.PorgASCIIArt)
-
 		yesbackup, _ := cmd.Flags().GetBool("backup")
 		noBackup, _ := cmd.Flags().GetBool("no-backup")
 		yesLoad, _ := cmd.Flags().GetBool("yes")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		keepBackups, _ := cmd.Flags().GetInt("keep-backups")
+		keepDays, _ := cmd.Flags().GetInt("keep-days")
+		asJSON, _ := cmd.Flags().GetBool("json")
+		missing, _ := cmd.Flags().GetString("missing")
+		strict, _ := cmd.Flags().GetBool("strict")
+		incremental, _ := cmd.Flags().GetBool("incremental")
+
+		if !asJSON {
+			fmt.Println(command.PorgASCIIArt)
+		}
 
 		backup := false
-		if yesbackup {
+		if dryRun {
+			backup = false
+		} else if yesbackup {
 			backup = true
 		} else if noBackup {
 			backup = false
@@ -66,20 +78,49 @@ var loadCmd = &cobra.Command{
 		}
 
 		conf := &command.Config{
-			Cmd:      cmd.Use,
-			File:     Config,
-			Cloud:    UseICloud,
-			Backup:   backup,
-			LogLevel: setLogLevel(Verbose),
+			Locator: command.Locator{
+				DB:        DBPath,
+				DockPlist: DockPlist,
+			},
+			Cmd:         cmd.Use,
+			File:        Config,
+			Cloud:       UseICloud,
+			Backup:      backup,
+			NoRestart:   NoRestart,
+			Strict:      strict,
+			Incremental: incremental,
+			Missing:     missing,
+			BusyTimeout: BusyTimeout,
+			DockTimeout: DockTimeout,
+			LockWait:    LockWait,
+			KeepBackups: keepBackups,
+			KeepDays:    keepDays,
+			LogLevel:    setLogLevel(Verbose),
 		}
 
 		if err := conf.Verify(); err != nil {
 			return err
 		}
 
+		if dryRun {
+			log.Info("Planning launchpad changes")
+			plan, err := command.PlanLoad(conf)
+			if err != nil {
+				return err
+			}
+			if err := plan.Print(os.Stdout, asJSON); err != nil {
+				return err
+			}
+			return conf.CheckStrict(plan.Report)
+		}
+
+		if err := command.Recover(conf); err != nil {
+			return err
+		}
+
 		if conf.Backup {
 			log.Debug("Backing up current launchpad settings")
-			if err := command.SaveConfig(conf); err != nil {
+			if _, err := command.BackupSettings(conf); err != nil {
 				return err
 			}
 		}
@@ -107,6 +148,13 @@ func init() {
 
 	loadCmd.Flags().BoolP("backup", "b", false, "Backup current launchpad settings")
 	loadCmd.Flags().BoolP("no-backup", "n", false, "Do NOT backup current launchpad settings")
+	loadCmd.Flags().Int("keep-backups", 10, "Number of backups to keep (0 keeps all)")
+	loadCmd.Flags().Int("keep-days", 0, "Days to keep backups for (0 keeps them for ever)")
 	loadCmd.Flags().BoolP("yes", "y", false, "Do not prompt user for confirmation")
+	loadCmd.Flags().Bool("dry-run", false, "Print the changes that would be made without making them")
+	loadCmd.Flags().Bool("json", false, "Print the --dry-run plan as JSON")
+	loadCmd.Flags().String("missing", "", "Placement policy for installed apps not in the config (append, new-page, folder:<name>, alphabetical-insert, ignore)")
+	loadCmd.Flags().Bool("strict", false, "Fail if the installed apps do not match the config")
+	loadCmd.Flags().Bool("incremental", false, "Update the existing layout in place, keeping the pages and folders that are still in the config")
 	loadCmd.MarkFlagsMutuallyExclusive("backup", "no-backup")
 }
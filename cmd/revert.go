@@ -22,6 +22,11 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"fmt"
+	"os"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/AlecAivazis/survey/v2/terminal"
 	"github.com/apex/log"
 	"github.com/blacktop/lporg/internal/command"
 	"github.com/spf13/cobra"
@@ -40,22 +45,113 @@ var revertCmd = &cobra.Command{
 			log.SetLevel(log.DebugLevel)
 		}
 
+		raw, _ := cmd.Flags().GetBool("raw")
+		at, _ := cmd.Flags().GetString("at")
+
 		conf := &command.Config{
-			Cmd:      cmd.Use,
-			File:     Config,
-			Cloud:    UseICloud,
-			LogLevel: setLogLevel(Verbose),
+			Locator: command.Locator{
+				DB:        DBPath,
+				DockPlist: DockPlist,
+			},
+			Cmd:         cmd.Use,
+			File:        Config,
+			Cloud:       UseICloud,
+			NoRestart:   NoRestart,
+			BusyTimeout: BusyTimeout,
+			DockTimeout: DockTimeout,
+			LockWait:    LockWait,
+			LogLevel:    setLogLevel(Verbose),
 		}
 
 		if err := conf.Verify(); err != nil {
 			return err
 		}
 
-		log.Info("Reverting launchpad settings")
-		return command.LoadConfig(conf)
+		if !raw { // a raw revert replaces whatever an interrupted run left behind
+			if err := command.Recover(conf); err != nil {
+				return err
+			}
+		}
+
+		if len(conf.File) > 0 { // revert to a config file given with --config
+			if raw || len(at) > 0 {
+				return fmt.Errorf("cannot use --config with --raw or --at")
+			}
+			log.Info("Reverting launchpad settings")
+			return command.LoadConfig(conf)
+		}
+
+		backups, err := command.ListBackups(conf)
+		if err != nil {
+			return err
+		}
+
+		if len(backups) == 0 && !raw {
+			// fall back to the single backup older versions wrote
+			legacy, err := conf.LegacyBackupFile()
+			if err != nil {
+				return err
+			}
+			if _, err := os.Stat(legacy); err == nil {
+				log.Warnf("no backups found, reverting to %s", legacy)
+				conf.File = legacy
+				return command.LoadConfig(conf)
+			}
+		}
+
+		if raw { // only raw backups can be restored raw
+			var rawBackups []*command.Backup
+			for _, b := range backups {
+				if b.Raw() {
+					rawBackups = append(rawBackups, b)
+				}
+			}
+			backups = rawBackups
+		}
+
+		var backup *command.Backup
+		if len(at) > 0 || len(backups) == 0 {
+			backup, err = command.FindBackup(backups, at)
+		} else {
+			backup, err = pickBackup(backups)
+		}
+		if err == terminal.InterruptErr {
+			log.Warn("Exiting...")
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if raw {
+			log.Infof("Restoring raw launchpad database and dock plist backup %s", backup.Name())
+			return command.RevertRaw(conf, backup)
+		}
+
+		log.Infof("Reverting launchpad settings to backup %s", backup.Name())
+		return command.RevertBackup(conf, backup)
 	},
 }
 
+// pickBackup prompts for the backup to revert to, newest first
+func pickBackup(backups []*command.Backup) (*command.Backup, error) {
+	options := make([]string, 0, len(backups))
+	for idx := len(backups) - 1; idx >= 0; idx-- {
+		options = append(options, backups[idx].String())
+	}
+	var choice int
+	prompt := &survey.Select{
+		Message: "Revert to which backup?",
+		Options: options,
+	}
+	if err := survey.AskOne(prompt, &choice); err != nil {
+		return nil, err
+	}
+	return backups[len(backups)-1-choice], nil
+}
+
 func init() {
 	rootCmd.AddCommand(revertCmd)
+
+	revertCmd.Flags().Bool("raw", false, "Restore the launchpad database and dock plist files of the backup exactly")
+	revertCmd.Flags().String("at", "", "Revert to the backup with this name, the latest one taken at or before this date or time, or 'latest'")
 }
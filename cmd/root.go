@@ -22,10 +22,14 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"errors"
 	"os"
+	"time"
 
 	"github.com/apex/log"
 	clihander "github.com/apex/log/handlers/cli"
+	"github.com/blacktop/lporg/internal/command"
+	"github.com/blacktop/lporg/internal/database"
 	"github.com/spf13/cobra"
 )
 
@@ -36,6 +40,18 @@ var (
 	Config string
 	// UseICloud boolean flag for using iCloud config
 	UseICloud bool
+	// DBPath stores the path to an explicit launchpad database
+	DBPath string
+	// DockPlist stores the path to an explicit Dock plist
+	DockPlist string
+	// NoRestart boolean flag for not restarting the Dock
+	NoRestart bool
+	// BusyTimeout stores how long to wait for the Dock to release the launchpad database
+	BusyTimeout time.Duration
+	// DockTimeout stores how long to wait for the Dock to restart and rebuild the launchpad database
+	DockTimeout time.Duration
+	// LockWait stores how long to wait for another lporg run to finish
+	LockWait time.Duration
 	// AppVersion stores the plugin's version
 	AppVersion string
 	// AppBuildTime stores the plugin's build time
@@ -55,12 +71,52 @@ func setLogLevel(verbose bool) int {
 	return int(log.WarnLevel)
 }
 
+// Exit codes
+const (
+	ExitError           = 1   // any other error
+	ExitConfigInvalid   = 2   // the config file cannot be parsed or is invalid
+	ExitConfigNotFound  = 3   // the config file does not exist
+	ExitDBNotFound      = 4   // there is no launchpad database
+	ExitUnknownSchema   = 5   // the launchpad database schema is unknown, nothing was written
+	ExitStrict          = 6   // --strict and the config and the installed apps disagree
+	ExitAppNotInstalled = 7   // --strict and the config lists apps that are not installed
+	ExitDockRestart     = 8   // the Dock could not be restarted
+	ExitLocked          = 9   // another lporg run is in progress
+	ExitInterrupted     = 130 // interrupted by Ctrl-C or SIGTERM
+)
+
+// exitCodes maps errors to exit codes, the first match wins
+var exitCodes = []struct {
+	err  error
+	code int
+}{
+	{command.ErrInterrupted, ExitInterrupted},
+	{command.ErrLocked, ExitLocked},
+	{database.ErrConfigNotFound, ExitConfigNotFound},
+	{database.ErrConfigInvalid, ExitConfigInvalid},
+	{database.ErrDBNotFound, ExitDBNotFound},
+	{database.ErrUnknownSchema, ExitUnknownSchema},
+	{database.ErrAppNotInstalled, ExitAppNotInstalled},
+	{command.ErrStrict, ExitStrict},
+	{command.ErrDockRestart, ExitDockRestart},
+}
+
+// exitCode returns the exit code for err
+func exitCode(err error) int {
+	for _, e := range exitCodes {
+		if errors.Is(err, e.err) {
+			return e.code
+		}
+	}
+	return ExitError
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		log.Error(err.Error())
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
 
@@ -70,6 +126,12 @@ func init() {
 	rootCmd.PersistentFlags().BoolVarP(&Verbose, "verbose", "V", false, "verbose output")
 	rootCmd.PersistentFlags().StringVarP(&Config, "config", "c", "", "config file (default is $CONFIG/lporg/config.yaml)")
 	rootCmd.PersistentFlags().BoolVar(&UseICloud, "icloud", false, "use iCloud for config")
+	rootCmd.PersistentFlags().StringVar(&DBPath, "db", "", "launchpad database file to use instead of the live Dock database")
+	rootCmd.PersistentFlags().StringVar(&DockPlist, "dock-plist", "", "Dock plist file to use instead of the live Dock preferences")
+	rootCmd.PersistentFlags().BoolVar(&NoRestart, "no-restart", false, "do not restart the Dock or change live system settings")
+	rootCmd.PersistentFlags().DurationVar(&BusyTimeout, "busy-timeout", 5*time.Second, "how long to wait for the launchpad database while it is busy")
+	rootCmd.PersistentFlags().DurationVar(&DockTimeout, "dock-timeout", 30*time.Second, "how long to wait for the Dock to restart and rebuild the launchpad database")
+	rootCmd.PersistentFlags().DurationVar(&LockWait, "lock-wait", 0, "how long to wait for another lporg run to finish (default fail right away)")
 	// Settings
 	rootCmd.CompletionOptions.HiddenDefaultCmd = true
 }
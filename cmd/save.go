@@ -40,11 +40,22 @@ var saveCmd = &cobra.Command{
 			log.SetLevel(log.DebugLevel)
 		}
 
+		bundleIDs, _ := cmd.Flags().GetBool("bundle-ids")
+
 		conf := &command.Config{
-			Cmd:      cmd.Use,
-			File:     Config,
-			Cloud:    UseICloud,
-			LogLevel: setLogLevel(Verbose),
+			Locator: command.Locator{
+				DB:        DBPath,
+				DockPlist: DockPlist,
+			},
+			Cmd:         cmd.Use,
+			File:        Config,
+			Cloud:       UseICloud,
+			BundleIDs:   bundleIDs,
+			NoRestart:   NoRestart,
+			BusyTimeout: BusyTimeout,
+			DockTimeout: DockTimeout,
+			LockWait:    LockWait,
+			LogLevel:    setLogLevel(Verbose),
 		}
 
 		if err := conf.Verify(); err != nil {
@@ -58,4 +69,6 @@ var saveCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(saveCmd)
+
+	saveCmd.Flags().Bool("bundle-ids", false, "Save apps by bundle identifier as well as title")
 }
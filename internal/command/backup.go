@@ -0,0 +1,501 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/blacktop/lporg/internal/database"
+	"github.com/blacktop/lporg/internal/dock"
+	"github.com/blacktop/lporg/internal/utils"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	backupManifest   = "backup.json"
+	backupConfig     = "config.yml"
+	backupDockPlist  = "com.apple.dock.plist"
+	backupTimeFormat = "2006-01-02T150405"
+)
+
+// dbFileSuffixes are the suffixes of the database file, its write-ahead log and its shared memory
+var dbFileSuffixes = []string{"", "-wal", "-shm"}
+
+// Backup is a timestamped directory under $CONFIG/lporg/backups holding the saved config
+// and copies of the files a load or default would change
+type Backup struct {
+	Dir       string    `json:"-"`
+	Created   time.Time `json:"created"`
+	Command   string    `json:"command"`
+	Apps      int       `json:"apps"`
+	Config    string    `json:"config,omitempty"`     // the saved YAML config
+	Database  string    `json:"database,omitempty"`   // where the database files were copied from
+	DBFiles   []string  `json:"db_files,omitempty"`   // db, db-wal and db-shm as far as they existed
+	DockPlist string    `json:"dock_plist,omitempty"` // where the Dock plist was copied from
+}
+
+// Name returns the name of the backup directory
+func (b *Backup) Name() string {
+	return filepath.Base(b.Dir)
+}
+
+// Raw returns true if the backup holds the raw database files
+func (b *Backup) Raw() bool {
+	return len(b.DBFiles) > 0
+}
+
+// String returns a one line summary of the backup
+func (b *Backup) String() string {
+	out := fmt.Sprintf("%s  %-7s  %4d apps", b.Created.Format("2006-01-02 15:04:05"), b.Command, b.Apps)
+	if b.Raw() {
+		out += "  (raw)"
+	}
+	return out
+}
+
+// backupsDir returns the directory backups are kept in. iCloud backups are kept per host.
+func (c *Config) backupsDir() (string, error) {
+	dir, err := c.configDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "backups")
+	if c.Cloud {
+		host, err := hostName()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(dir, host)
+	}
+	return dir, nil
+}
+
+// newBackup creates an empty backup directory named after the current time
+func (c *Config) newBackup() (*Backup, error) {
+	root, err := c.backupsDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(root, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create backups dir: %w", err)
+	}
+
+	b := &Backup{Created: time.Now(), Command: c.Cmd}
+	name := b.Created.Format(backupTimeFormat)
+	for idx := 1; ; idx++ {
+		b.Dir = filepath.Join(root, name)
+		err := os.Mkdir(b.Dir, 0750)
+		if err == nil {
+			return b, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to create backup dir: %w", err)
+		}
+		name = fmt.Sprintf("%s-%d", b.Created.Format(backupTimeFormat), idx)
+	}
+}
+
+// save writes the backup manifest
+func (b *Backup) save() error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(b.Dir, backupManifest), data, 0640)
+}
+
+// ListBackups returns the backups, oldest first
+func ListBackups(c *Config) ([]*Backup, error) {
+	root, err := c.backupsDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read backups dir: %w", err)
+	}
+
+	var backups []*Backup
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(root, entry.Name())
+		data, err := os.ReadFile(filepath.Join(dir, backupManifest))
+		if err != nil {
+			utils.Indent(log.WithError(err).WithField("dir", dir).Debug, 2)("skipping backup")
+			continue
+		}
+		b := &Backup{Dir: dir}
+		if err := json.Unmarshal(data, b); err != nil {
+			utils.Indent(log.WithError(err).WithField("dir", dir).Warn, 2)("skipping invalid backup")
+			continue
+		}
+		backups = append(backups, b)
+	}
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].Created.Before(backups[j].Created)
+	})
+
+	return backups, nil
+}
+
+// BackupSettings saves the current layout and copies the Launchpad database files and the
+// Dock plist byte for byte into a new backup, then removes the backups past retention
+func BackupSettings(c *Config) (*Backup, error) {
+	unlock, err := c.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	b, err := c.newBackup()
+	if err != nil {
+		return nil, err
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+
+	if err := b.fill(ctx, c); err != nil {
+		os.RemoveAll(b.Dir)
+		return nil, err
+	}
+
+	log.Infof(bold, "successfully backed up current settings to: "+b.Dir)
+
+	if err := PruneBackups(c, b); err != nil {
+		utils.Indent(log.WithError(err).Warn, 2)("failed to remove old backups")
+	}
+
+	return b, nil
+}
+
+// fill copies the raw files and saves the config into the backup and writes its manifest
+func (b *Backup) fill(ctx context.Context, c *Config) error {
+	loc, err := c.LocateLaunchpadDB(ctx)
+	if err != nil {
+		return err
+	}
+	plist, err := c.DockPlistPath()
+	if err != nil {
+		return err
+	}
+
+	b.Database = loc.Path
+	if err := b.copyDatabase(ctx, c, loc.Path); err != nil {
+		return fmt.Errorf("failed to backup launchpad database: %w", err)
+	}
+
+	copied, err := copyFile(plist, filepath.Join(b.Dir, backupDockPlist))
+	if err != nil {
+		return fmt.Errorf("failed to backup dock plist: %w", err)
+	}
+	if copied {
+		b.DockPlist = plist
+	} else {
+		utils.Indent(log.WithField("plist", plist).Warn, 2)("dock plist not found, not backing it up")
+	}
+
+	conf, err := c.readConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := writeConfig(filepath.Join(b.Dir, backupConfig), conf); err != nil {
+		return err
+	}
+	b.Config = backupConfig
+	b.Apps = conf.Apps.Count()
+
+	if err := b.save(); err != nil {
+		return fmt.Errorf("failed to write backup manifest: %w", err)
+	}
+	return nil
+}
+
+// copyDatabase copies the database file and its WAL and shared memory files byte for byte
+// into the backup. The live Dock keeps writing to them, so it is stopped while they are
+// copied. With --no-restart it cannot be, so a consistent snapshot is taken instead.
+func (b *Backup) copyDatabase(ctx context.Context, c *Config, file string) (err error) {
+	if c.LiveDB() {
+		if c.NoRestart {
+			utils.Indent(log.Warn, 2)("--no-restart: backing up a snapshot of the launchpad database instead of its raw files")
+			if err := database.Snapshot(file, filepath.Join(b.Dir, "db"), c.BusyTimeout); err != nil {
+				return err
+			}
+			b.DBFiles = []string{"db"}
+			return nil
+		}
+		oldPID, perr := dockPID(ctx)
+		if perr != nil {
+			return fmt.Errorf("%w: %w", ErrDockRestart, perr)
+		}
+		if err := dock.Stop(ctx); err != nil {
+			return err
+		}
+		defer func() {
+			// bring the Dock back even if Ctrl-C interrupted the copy
+			if serr := c.startDock(context.WithoutCancel(ctx), oldPID, file); serr != nil && err == nil {
+				err = serr
+			}
+		}()
+	}
+
+	for _, suffix := range dbFileSuffixes {
+		copied, err := copyFile(file+suffix, filepath.Join(b.Dir, "db"+suffix))
+		if err != nil {
+			return err
+		}
+		if copied {
+			b.DBFiles = append(b.DBFiles, "db"+suffix)
+		}
+	}
+	if len(b.DBFiles) == 0 || b.DBFiles[0] != "db" {
+		return fmt.Errorf("%w: %s", database.ErrDBNotFound, file)
+	}
+	return nil
+}
+
+// PruneBackups removes the backups past the --keep-backups count or --keep-days age,
+// except for keep
+func PruneBackups(c *Config, keep *Backup) error {
+	unlock, err := c.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	backups, err := ListBackups(c)
+	if err != nil {
+		return err
+	}
+	cutoff := time.Now().AddDate(0, 0, -c.KeepDays)
+	for idx, b := range backups {
+		if b.Dir == keep.Dir {
+			continue
+		}
+		tooMany := c.KeepBackups > 0 && idx < len(backups)-c.KeepBackups
+		tooOld := c.KeepDays > 0 && b.Created.Before(cutoff)
+		if !tooMany && !tooOld {
+			continue
+		}
+		if err := os.RemoveAll(b.Dir); err != nil {
+			return fmt.Errorf("failed to remove backup '%s': %w", b.Name(), err)
+		}
+		utils.Indent(log.WithField("backup", b.Name()).Info, 2)("removed old backup")
+	}
+	return nil
+}
+
+// backupTimeLayouts are the --at time formats and the end of the span a time in each covers
+var backupTimeLayouts = []struct {
+	layout string
+	end    func(time.Time) time.Time
+}{
+	{"2006-01-02T15:04:05", func(t time.Time) time.Time { return t.Add(time.Second) }},
+	{"2006-01-02 15:04:05", func(t time.Time) time.Time { return t.Add(time.Second) }},
+	{"2006-01-02T15:04", func(t time.Time) time.Time { return t.Add(time.Minute) }},
+	{"2006-01-02 15:04", func(t time.Time) time.Time { return t.Add(time.Minute) }},
+	{"2006-01-02", func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }},
+}
+
+// FindBackup returns the backup named at, the latest one taken at or before at if it is
+// a local date or time (e.g. 2024-05-01 or 2024-05-01T13:30), or the latest one for "latest"
+func FindBackup(backups []*Backup, at string) (*Backup, error) {
+	if len(backups) == 0 {
+		return nil, fmt.Errorf("no backups found (run load or default with --backup to create one)")
+	}
+	if at == "latest" {
+		return backups[len(backups)-1], nil
+	}
+	for _, b := range backups {
+		if b.Name() == at {
+			return b, nil
+		}
+	}
+	for _, l := range backupTimeLayouts {
+		t, err := time.ParseInLocation(l.layout, at, time.Local)
+		if err != nil {
+			continue
+		}
+		until := l.end(t)
+		for idx := len(backups) - 1; idx >= 0; idx-- {
+			if backups[idx].Created.Before(until) {
+				return backups[idx], nil
+			}
+		}
+		return nil, fmt.Errorf("no backup taken at or before %s (oldest is %s)", at, backups[0].Name())
+	}
+	return nil, fmt.Errorf("invalid --at '%s': must be a backup name, a date, a date and time or 'latest'", at)
+}
+
+// RevertBackup loads the config saved in the backup
+func RevertBackup(c *Config, b *Backup) error {
+	if len(b.Config) == 0 {
+		return fmt.Errorf("backup '%s' has no saved config (try --raw)", b.Name())
+	}
+	c.File = filepath.Join(b.Dir, b.Config)
+	return LoadConfig(c)
+}
+
+// RevertRaw restores the Launchpad database files and Dock plist of the backup exactly
+// as they were. The files are copied next to the live ones first and renamed into place
+// while the live Dock is stopped, then the Dock is started and waited for.
+func RevertRaw(c *Config, b *Backup) (err error) {
+	unlock, err := c.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if !b.Raw() {
+		return fmt.Errorf("backup '%s' has no raw launchpad database files", b.Name())
+	}
+
+	// restore to the files given on the command line, otherwise to where they were copied from
+	db := b.Database
+	if !c.LiveDB() {
+		if db, err = filepath.Abs(c.DB); err != nil {
+			return err
+		}
+	}
+	plist := b.DockPlist
+	if !c.LiveDockPlist() {
+		if plist, err = c.DockPlistPath(); err != nil {
+			return err
+		}
+	}
+
+	log.Infof(bold, "RESTORING RAW BACKUP "+b.Dir)
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	// copy everything next to where it goes first, so a failed copy leaves the live files alone
+	var staged []string
+	defer func() {
+		for _, tmp := range staged {
+			os.Remove(tmp)
+		}
+	}()
+	stage := func(src, dst string) error {
+		tmp := dst + ".lporg-restore"
+		staged = append(staged, tmp)
+		copied, err := copyFile(src, tmp)
+		if err != nil {
+			return err
+		}
+		if !copied {
+			return fmt.Errorf("%s is missing from the backup: %w", filepath.Base(src), os.ErrNotExist)
+		}
+		return nil
+	}
+	for _, name := range b.DBFiles {
+		if err := stage(filepath.Join(b.Dir, name), db+name[len("db"):]); err != nil {
+			return fmt.Errorf("failed to restore launchpad database: %w", err)
+		}
+	}
+	if len(plist) > 0 {
+		if err := stage(filepath.Join(b.Dir, backupDockPlist), plist); err != nil {
+			return fmt.Errorf("failed to restore dock plist: %w", err)
+		}
+	}
+
+	live := (c.LiveDB() || c.LiveDockPlist()) && !c.NoRestart
+	if live {
+		oldPID, perr := dockPID(ctx)
+		if perr != nil {
+			return fmt.Errorf("%w: %w", ErrDockRestart, perr)
+		}
+		if err := dock.Stop(ctx); err != nil {
+			return err
+		}
+		defer func() {
+			// bring the Dock back even if Ctrl-C interrupted the restore
+			if serr := c.startDock(context.WithoutCancel(ctx), oldPID, db); serr != nil && err == nil {
+				err = serr
+			}
+		}()
+	} else if c.LiveDB() || c.LiveDockPlist() {
+		utils.Indent(log.Warn, 2)("--no-restart: the running Dock may overwrite the restored files")
+	}
+
+	// remove the WAL and shared memory files the backup did not have so they are not
+	// applied to the restored database
+	for _, suffix := range dbFileSuffixes[1:] {
+		if slices.Contains(b.DBFiles, "db"+suffix) {
+			continue
+		}
+		if err := os.Remove(db + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove launchpad database file: %w", err)
+		}
+	}
+	for _, name := range b.DBFiles {
+		dst := db + name[len("db"):]
+		if err := os.Rename(dst+".lporg-restore", dst); err != nil {
+			return fmt.Errorf("failed to restore launchpad database: %w", err)
+		}
+		utils.Indent(log.WithField("path", dst).Info, 2)("restored launchpad database file")
+	}
+
+	// the restored files replace anything an interrupted run left to finish
+	if j, err := readJournal(); err == nil && j != nil {
+		if err := j.remove(); err != nil {
+			return err
+		}
+	}
+
+	if len(plist) > 0 {
+		if err := os.Rename(plist+".lporg-restore", plist); err != nil {
+			return fmt.Errorf("failed to restore dock plist: %w", err)
+		}
+		utils.Indent(log.WithField("path", plist).Info, 2)("restored dock plist")
+		if c.LiveDockPlist() && !c.NoRestart {
+			// the preferences daemon caches the plist, so it has to be told about the change
+			if err := dock.ImportFile(ctx, plist); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// copyFile copies src to dst byte for byte keeping its permissions. It returns false
+// without an error if src does not exist.
+func copyFile(src, dst string) (bool, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return false, err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return false, err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return false, err
+	}
+	if err := out.Close(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
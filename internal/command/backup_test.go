@@ -0,0 +1,300 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/blacktop/lporg/internal/database/testdb"
+	"github.com/blacktop/lporg/internal/runner/runnertest"
+	"golang.org/x/exp/slices"
+)
+
+// setConfigDir points the user config dir at a temp dir
+func setConfigDir(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	return home
+}
+
+func TestBackupSettings_RevertRaw(t *testing.T) {
+	tests := []struct {
+		name       string
+		live       bool  // back up and restore the live files with a fake Dock
+		noRestart  bool  // --no-restart, so the live Dock is never stopped
+		pids       []int // Dock PIDs found before stopping it and while waiting, for the backup and the restore
+		removeDB   bool  // the backup lost its database file
+		wantFiles  []string
+		wantRun    []string
+		wantErr    []error
+		wantRevert bool // the files are restored even if an error is returned
+	}{
+		{name: "offline", wantFiles: []string{"db", "db-wal"}, wantRevert: true},
+		{
+			name:      "live",
+			live:      true,
+			pids:      []int{100, 200, 200, 0, 300},
+			wantFiles: []string{"db", "db-wal"},
+			wantRun: []string{
+				"pgrep", "/bin/launchctl unload", "/bin/launchctl load", "/bin/launchctl start", "pgrep",
+				"pgrep", "/bin/launchctl unload", "/bin/launchctl load", "/bin/launchctl start", "pgrep", "pgrep",
+			},
+			wantRevert: true,
+		},
+		{
+			// the Dock cannot be stopped, so the backup is a snapshot rather than the raw files
+			name:       "live with --no-restart",
+			live:       true,
+			noRestart:  true,
+			wantFiles:  []string{"db"},
+			wantRun:    []string{},
+			wantRevert: true,
+		},
+		{
+			name:       "Dock does not come back",
+			live:       true,
+			pids:       []int{100, 200, 200, 0},
+			wantFiles:  []string{"db", "db-wal"},
+			wantErr:    []error{ErrDockRestart},
+			wantRevert: true,
+		},
+		{
+			// the copy fails before anything is swapped, so the live files are left alone
+			name:      "copy fails",
+			removeDB:  true,
+			wantFiles: []string{"db", "db-wal"},
+			wantErr:   []error{os.ErrNotExist},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log.SetLevel(log.ErrorLevel)
+			home := setConfigDir(t)
+
+			c := &Config{Cmd: "load", NoRestart: tt.noRestart, DockTimeout: 100 * time.Millisecond}
+			var db, plist string
+			if tt.live {
+				db = liveDB(t, testdb.Generate(3, 35))
+				plist = filepath.Join(home, dockPlistPath)
+				if err := os.MkdirAll(filepath.Dir(plist), 0755); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				db = testdb.New(t, testdb.Generate(3, 35))
+				plist = filepath.Join(home, "com.apple.dock.plist")
+				c.Locator = Locator{DB: db, DockPlist: plist}
+			}
+			data, err := os.ReadFile(filepath.Join("..", "..", ".hack", "test", "com.apple.dock.plist"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			write := func(path string, data []byte) {
+				if err := os.WriteFile(path, data, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			write(plist, data)
+			write(db+"-wal", nil) // an empty WAL is ignored by sqlite
+			orig, err := os.ReadFile(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var fake *runnertest.Fake
+			if tt.live {
+				fake = fakeDock(t, runnertest.Result{}, tt.pids...)
+			}
+			b, err := BackupSettings(c)
+			if err != nil {
+				t.Fatalf("BackupSettings() error = %v", err)
+			}
+			if !reflect.DeepEqual(b.DBFiles, tt.wantFiles) {
+				t.Errorf("BackupSettings() db files = %v, want %v", b.DBFiles, tt.wantFiles)
+			}
+			exact := !tt.noRestart // a snapshot has the same content but not the same bytes
+			if got, err := os.ReadFile(filepath.Join(b.Dir, "db")); err != nil || (exact && !bytes.Equal(got, orig)) {
+				t.Errorf("BackupSettings() db is not a byte for byte copy: %v", err)
+			}
+			if b.Apps != 3 {
+				t.Errorf("BackupSettings() apps = %d, want 3", b.Apps)
+			}
+			if _, err := os.Stat(filepath.Join(b.Dir, b.Config)); err != nil {
+				t.Errorf("BackupSettings() did not save the config: %v", err)
+			}
+
+			// a load goes wrong
+			write(db, []byte("garbage"))
+			write(db+"-wal", []byte("write-ahead log"))
+			write(db+"-shm", []byte("shared memory"))
+			write(plist, []byte("changed"))
+			if tt.removeDB {
+				if err := os.Remove(filepath.Join(b.Dir, "db")); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			backups, err := ListBackups(c)
+			if err != nil || len(backups) != 1 || backups[0].Dir != b.Dir || backups[0].Command != "load" {
+				t.Fatalf("ListBackups() = %v, %v, want the backup in %s", backups, err, b.Dir)
+			}
+
+			err = RevertRaw(c, backups[0])
+			if len(tt.wantErr) == 0 && err != nil {
+				t.Fatalf("RevertRaw() error = %v", err)
+			}
+			for _, want := range tt.wantErr {
+				if !errors.Is(err, want) {
+					t.Fatalf("RevertRaw() error = %v, want %v", err, want)
+				}
+			}
+			if tt.wantRun != nil {
+				got := []string{}
+				for _, cmd := range fake.Calls() {
+					if cmd.Name == "/bin/launchctl" {
+						got = append(got, cmd.Name+" "+cmd.Args[0])
+					} else if cmd.Name == "pgrep" {
+						got = append(got, cmd.Name)
+					}
+				}
+				if !reflect.DeepEqual(got, tt.wantRun) {
+					t.Errorf("BackupSettings() and RevertRaw() ran %q, want %q", got, tt.wantRun)
+				}
+			}
+
+			leftovers, err := filepath.Glob(filepath.Join(filepath.Dir(db), "*.lporg-restore"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(leftovers) > 0 {
+				t.Errorf("RevertRaw() left %v behind", leftovers)
+			}
+			got, err := os.ReadFile(plist)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.wantRevert {
+				if string(got) != "changed" {
+					t.Errorf("RevertRaw() changed the dock plist although the restore failed")
+				}
+				if got, err := os.ReadFile(db); err != nil || string(got) != "garbage" {
+					t.Errorf("RevertRaw() changed the database although the restore failed: %v", err)
+				}
+				return
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("RevertRaw() dock plist differs from the backup")
+			}
+			if got, err := os.ReadFile(db); err != nil || (exact && !bytes.Equal(got, orig)) {
+				t.Errorf("RevertRaw() db differs from the original: %v", err)
+			}
+			for _, suffix := range []string{"-wal", "-shm"} {
+				_, err := os.Stat(db + suffix)
+				if want := slices.Contains(tt.wantFiles, "db"+suffix); want != (err == nil) {
+					t.Errorf("RevertRaw() db%s exists = %v, want %v", suffix, err == nil, want)
+				}
+			}
+			conf, err := (&Config{Locator: Locator{DB: db, DockPlist: plist}}).readConfig(context.Background())
+			if err != nil {
+				t.Fatalf("readConfig() of the restored database error = %v", err)
+			}
+			if conf.Apps.Count() != 3 {
+				t.Errorf("RevertRaw() restored %d apps, want 3", conf.Apps.Count())
+			}
+		})
+	}
+}
+
+func TestPruneBackups(t *testing.T) {
+	log.SetLevel(log.ErrorLevel)
+	setConfigDir(t)
+
+	now := time.Now()
+	c := &Config{Cmd: "load"}
+	var backups []*Backup
+	for _, age := range []int{30, 10, 3, 2, 1, 0} { // days old, the last one is the new backup
+		b, err := c.newBackup()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.Created = now.AddDate(0, 0, -age)
+		if err := b.save(); err != nil {
+			t.Fatal(err)
+		}
+		backups = append(backups, b)
+	}
+
+	tests := []struct {
+		name     string
+		keep     int
+		keepDays int
+		want     int // backups left, the newest ones
+	}{
+		{name: "keep all", want: 6},
+		{name: "keep 4", keep: 4, want: 4},
+		{name: "keep 5 days", keepDays: 5, want: 4},
+		{name: "keep 3 and 5 days", keep: 3, keepDays: 5, want: 3},
+		{name: "keep 1", keep: 1, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.KeepBackups, c.KeepDays = tt.keep, tt.keepDays
+			if err := PruneBackups(c, backups[len(backups)-1]); err != nil {
+				t.Fatalf("PruneBackups() error = %v", err)
+			}
+			got, err := ListBackups(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != tt.want || got[len(got)-1].Dir != backups[len(backups)-1].Dir {
+				t.Errorf("PruneBackups() left %d backups, want the newest %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestFindBackup(t *testing.T) {
+	at := func(s string) *Backup {
+		created, err := time.ParseInLocation("2006-01-02 15:04:05", s, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &Backup{Dir: filepath.Join("backups", created.Format(backupTimeFormat)), Created: created}
+	}
+	backups := []*Backup{at("2024-05-01 09:00:00"), at("2024-05-01 13:30:10"), at("2024-05-03 08:00:00")}
+
+	tests := []struct {
+		name    string
+		at      string
+		want    string
+		wantErr bool
+	}{
+		{name: "latest", at: "latest", want: "2024-05-03T080000"},
+		{name: "name", at: "2024-05-01T090000", want: "2024-05-01T090000"},
+		{name: "date", at: "2024-05-01", want: "2024-05-01T133010"},
+		{name: "date between", at: "2024-05-02", want: "2024-05-01T133010"},
+		{name: "minute", at: "2024-05-01 13:30", want: "2024-05-01T133010"},
+		{name: "before minute", at: "2024-05-01T13:29", want: "2024-05-01T090000"},
+		{name: "second", at: "2024-05-01T13:30:09", want: "2024-05-01T090000"},
+		{name: "too early", at: "2024-04-30", wantErr: true},
+		{name: "invalid", at: "yesterday", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FindBackup(backups, tt.at)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FindBackup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.Name() != tt.want {
+				t.Errorf("FindBackup() = %s, want %s", got.Name(), tt.want)
+			}
+		})
+	}
+}
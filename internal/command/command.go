@@ -2,11 +2,12 @@
 package command
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
+	"time"
 
 	"github.com/apex/log"
 	"github.com/blacktop/lporg/internal/database"
@@ -15,6 +16,7 @@ import (
 	"github.com/blacktop/lporg/internal/utils"
 	"github.com/glebarez/sqlite"
 	"github.com/pkg/errors"
+	"golang.org/x/exp/slices"
 	"gopkg.in/yaml.v3"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -24,11 +26,23 @@ const bold = "\033[1m%s\033[0m"
 
 // Config is the command config
 type Config struct {
-	Cmd      string
-	File     string
-	Cloud    bool
-	Backup   bool
-	LogLevel int
+	Locator
+
+	Cmd         string
+	File        string
+	Cloud       bool
+	Backup      bool
+	BundleIDs   bool
+	NoRestart   bool
+	Strict      bool
+	Incremental bool
+	Missing     string
+	BusyTimeout time.Duration
+	DockTimeout time.Duration // how long to wait for the Dock to restart and rebuild its database
+	LockWait    time.Duration // how long to wait for another run to finish, 0 to fail fast
+	KeepBackups int           // backups to keep, 0 for all
+	KeepDays    int           // days to keep backups for, 0 for ever
+	LogLevel    int
 }
 
 // Verify will verify the command config
@@ -36,53 +50,39 @@ func (c *Config) Verify() error {
 	if c.Cloud && len(c.File) > 0 {
 		return fmt.Errorf("cannot use --config with --icloud")
 	}
+	if len(c.Missing) > 0 {
+		if _, err := database.ParsePlacement(c.Missing); err != nil {
+			return fmt.Errorf("invalid --missing: %w", err)
+		}
+	}
 
 	switch c.Cmd {
-	case "revert":
-		if c.Cloud {
-			iCloudPath, err := getiCloudDrivePath()
-			if err != nil {
-				return fmt.Errorf("get iCloud drive path failed")
-			}
-			host, err := os.Hostname()
-			if err != nil {
-				return fmt.Errorf("failed to get hostname")
-			}
-			c.File = filepath.Join(iCloudPath, ".config", "lporg", strings.TrimRight(host, ".local")+".yml.bak")
-		} else {
-			if len(c.File) == 0 { // set DEFAULT config file
-				confDir, err := os.UserConfigDir()
-				if err != nil {
-					return fmt.Errorf("failed to get user config dir")
-				}
-				c.File = filepath.Join(confDir, "lporg", "config.yml.bak")
-			}
+	case "revert", "backups":
+		if len(c.File) == 0 { // a backup is picked later
+			return nil
 		}
 	case "load":
 		if len(c.File) == 0 && !c.Cloud {
 			return fmt.Errorf("must supply --config file OR use --icloud")
 		}
-		fallthrough
-	default:
-		if c.Cloud { // use iCloud to store config
-			iCloudPath, err := getiCloudDrivePath()
-			if err != nil {
-				return fmt.Errorf("get iCloud drive path failed")
-			}
-			host, err := os.Hostname()
-			if err != nil {
-				return fmt.Errorf("failed to get hostname")
-			}
-			c.File = filepath.Join(iCloudPath, ".config", "lporg", strings.TrimRight(host, ".local")+".yml")
-		} else {
-			if len(c.File) == 0 { // set DEFAULT config file
-				confDir, err := os.UserConfigDir()
-				if err != nil {
-					return fmt.Errorf("failed to get user config dir")
-				}
-				c.File = filepath.Join(confDir, "lporg", "config.yml")
-			}
+	}
+
+	if c.Cloud { // use iCloud to store config
+		dir, err := c.configDir()
+		if err != nil {
+			return err
+		}
+		host, err := hostName()
+		if err != nil {
+			return err
 		}
+		c.File = filepath.Join(dir, host+".yml")
+	} else if len(c.File) == 0 { // set DEFAULT config file
+		dir, err := c.configDir()
+		if err != nil {
+			return err
+		}
+		c.File = filepath.Join(dir, "config.yml")
 	}
 
 	if err := os.MkdirAll(filepath.Dir(c.File), 0750); err != nil {
@@ -94,20 +94,270 @@ func (c *Config) Verify() error {
 	return nil
 }
 
-func parsePages(root int, parentMapping map[int][]database.Item) (database.Apps, error) {
+// configDir returns the lporg config directory, in iCloud Drive when using iCloud
+func (c *Config) configDir() (string, error) {
+	if c.Cloud {
+		iCloudPath, err := getiCloudDrivePath()
+		if err != nil {
+			return "", fmt.Errorf("get iCloud drive path failed")
+		}
+		return filepath.Join(iCloudPath, ".config", "lporg"), nil
+	}
+	confDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user config dir")
+	}
+	return filepath.Join(confDir, "lporg"), nil
+}
+
+// LegacyBackupFile returns the single YAML backup older versions of lporg overwrote on every run
+func (c *Config) LegacyBackupFile() (string, error) {
+	dir, err := c.configDir()
+	if err != nil {
+		return "", err
+	}
+	if c.Cloud {
+		host, err := hostName()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, host+".yml.bak"), nil
+	}
+	return filepath.Join(dir, "config.yml.bak"), nil
+}
+
+// locateLaunchPad finds the Launchpad database without opening it
+func (c *Config) locateLaunchPad(ctx context.Context) (*database.LaunchPad, error) {
+	loc, err := c.LocateLaunchpadDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lpad := &database.LaunchPad{File: loc.Path, Folder: filepath.Dir(loc.Path)}
+	utils.Indent(log.WithFields(log.Fields{"database": lpad.File, "location": loc.Source}).Info, 2)("found launchpad database")
+	return lpad, nil
+}
+
+// openLaunchPad finds and opens the Launchpad database. If reset is true and the
+// database belongs to the running Dock it is removed first so the Dock rebuilds it,
+// unless its schema is unknown.
+func (c *Config) openLaunchPad(ctx context.Context, reset bool) (*database.LaunchPad, error) {
+	lpad, err := c.locateLaunchPad(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.openDB(lpad, lpad.File); err != nil {
+		return nil, err
+	}
+
+	if reset {
+		if c.LiveDB() && !c.NoRestart {
+			// removing the database is the most destructive write lporg makes, so refuse
+			// an unknown schema before it rather than when the layout is written
+			if err := lpad.CheckWritable(); err != nil {
+				lpad.Close()
+				return nil, err
+			}
+			lpad.Close()
+
+			// start from a clean slate
+			if err := c.removeDatabaseFiles(ctx, lpad.File); err != nil {
+				return nil, err
+			}
+			lpad = &database.LaunchPad{File: lpad.File, Folder: lpad.Folder}
+			if err := c.openDB(lpad, lpad.File); err != nil {
+				return nil, err
+			}
+		} else {
+			utils.Indent(log.Info, 2)("offline mode: using existing launchpad database")
+		}
+	}
+
+	return lpad, nil
+}
+
+// openSnapshot finds the Launchpad database and opens a consistent copy of it, so
+// reads do not race the Dock writing to it. The returned func closes and removes the copy.
+func (c *Config) openSnapshot(ctx context.Context) (*database.LaunchPad, func(), error) {
+	lpad, err := c.locateLaunchPad(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "lporg-snapshot-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create snapshot dir: %w", err)
+	}
+	snapshot := filepath.Join(dir, "db")
+	if err := database.Snapshot(lpad.File, snapshot, c.BusyTimeout); err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+	utils.Indent(log.WithField("snapshot", snapshot).Debug, 2)("copied launchpad database")
+
+	if err := c.openDB(lpad, snapshot); err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+
+	return lpad, func() {
+		lpad.Close()
+		os.RemoveAll(dir)
+	}, nil
+}
+
+// openScratch opens a snapshot of the Launchpad database that a dry run can apply the
+// layout to without touching the live database, even if its schema is unknown
+func (c *Config) openScratch(ctx context.Context) (*database.LaunchPad, func(), error) {
+	lpad, cleanup, err := c.openSnapshot(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	lpad.Scratch = true
+	if schema, err := lpad.Schema(); err == nil && schema.Version == database.SchemaUnknown {
+		utils.Indent(log.Warn, 2)("planning against an unknown schema, the plan is a best guess")
+	}
+	return lpad, cleanup, nil
+}
+
+// openDB opens the database file for lpad and detects its schema
+func (c *Config) openDB(lpad *database.LaunchPad, file string) (err error) {
+	lpad.DB, err = gorm.Open(sqlite.Open(database.DSN(file, c.BusyTimeout)), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.LogLevel(c.LogLevel)),
+	})
+	if err != nil {
+		return err
+	}
+
+	schema, err := lpad.Schema()
+	if err != nil {
+		lpad.Close()
+		return err
+	}
+	if schema.Version == database.SchemaUnknown {
+		utils.Indent(log.WithField("problems", strings.Join(schema.Problems, "; ")).Warn, 2)("unknown launchpad database schema (read only)")
+	} else {
+		utils.Indent(log.WithField("version", schema.Version).Debug, 2)("detected launchpad database schema")
+	}
+
+	return nil
+}
+
+// resolveGrid fills in the grid dimensions missing from the config from the Dock prefs
+func (c *Config) resolveGrid(grid database.Grid) database.Grid {
+	var prefs database.Grid
+	if dPlist, err := c.loadDockPlist(); err == nil {
+		prefs.Rows = dPlist.SpringboardRows
+		prefs.Columns = dPlist.SpringboardColumns
+	} else {
+		utils.Indent(log.WithError(err).Debug, 2)("unable to read launchpad grid from dock plist")
+	}
+	grid = grid.WithDefaults(prefs)
+	utils.Indent(log.WithFields(log.Fields{"rows": grid.Rows, "columns": grid.Columns}).Info, 2)("using launchpad grid")
+	return grid
+}
+
+// resolveMissing returns the --missing placement policy if set, otherwise the config's
+func (c *Config) resolveMissing(policy database.Placement) database.Placement {
+	if len(c.Missing) > 0 {
+		if p, err := database.ParsePlacement(c.Missing); err == nil { // validated in Verify
+			return p
+		}
+	}
+	return policy
+}
+
+// CheckStrict returns an error if running with --strict and the config and
+// the installed apps disagree
+func (c *Config) CheckStrict(report *database.MissingReport) error {
+	if !c.Strict || report.Empty() {
+		return nil
+	}
+	for _, app := range report.Unlisted {
+		utils.Indent(log.WithField("app", app).Error, 2)("installed but not in config")
+	}
+	for _, app := range report.Uninstalled {
+		utils.Indent(log.WithField("app", app).Error, 2)("in config but not installed")
+	}
+	for _, folder := range report.EmptyFolders {
+		utils.Indent(log.WithField("folder", folder).Error, 2)("folder has no installed apps")
+	}
+	if len(report.Uninstalled) > 0 {
+		return fmt.Errorf("--strict: %w: %w: %s", ErrStrict, database.ErrAppNotInstalled, report)
+	}
+	return fmt.Errorf("--strict: %w: %s", ErrStrict, report)
+}
+
+// rolledBack describes what a failed layout transaction left behind. The transaction
+// cannot undo the reset of the live database that happened before it.
+func (c *Config) rolledBack(reset bool) string {
+	if reset && c.LiveDB() && !c.NoRestart {
+		return "layout changes rolled back, but the launchpad was already reset to the Dock's default layout"
+	}
+	return "all changes rolled back"
+}
+
+// checkStrictBeforeReset works out on a scratch copy of the database whether the config
+// passes --strict, so a load that resets the live database fails before changing anything
+func (c *Config) checkStrictBeforeReset(ctx context.Context, config database.Config) error {
+	lpad, cleanup, err := c.openScratch(ctx)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	lpad.Config = config
+	report, err := rebuild(lpad)
+	if err != nil {
+		return err
+	}
+	return c.CheckStrict(report)
+}
+
+// loadDockPlist loads the located Dock plist
+func (c *Config) loadDockPlist() (*dock.Plist, error) {
+	path, err := c.DockPlistPath()
+	if err != nil {
+		return nil, err
+	}
+	return dock.LoadDockPlist(path)
+}
+
+// saveDockPlist writes the Dock plist back to where it was located
+func (c *Config) saveDockPlist(ctx context.Context, p *dock.Plist) error {
+	if !c.LiveDockPlist() {
+		path, err := c.DockPlistPath()
+		if err != nil {
+			return err
+		}
+		return p.SaveAs(path)
+	}
+	if c.NoRestart {
+		return p.Import(ctx)
+	}
+	return p.Save(ctx)
+}
+
+func parsePages(pages []database.LayoutPage, bundleIDs bool) (database.Apps, error) {
 	var apps database.Apps
 
-	for pageNum, page := range parentMapping[root] {
+	ref := func(app database.App) database.AppRef {
+		if bundleIDs {
+			return app.Ref()
+		}
+		return database.AppRef{Title: app.Title}
+	}
+
+	for pageNum, page := range pages {
 
 		log.Infof("page number: %d", pageNum+1)
 
 		p := database.Page{Number: pageNum + 1}
 
-		for _, item := range parentMapping[page.ID] {
+		for _, item := range page.Items {
 			switch item.Type {
 			case database.ApplicationType:
 				utils.Indent(log.WithField("title", item.App.Title).Info, 2)("found app")
-				p.Items = append(p.Items, item.App.Title)
+				p.Items = append(p.Items, database.AppItem(ref(item.App)))
 			// case database.WidgetType:
 			// 	utils.Indent(log.WithField("title", item.Widget.Title).Info)("found widget")
 			// 	p.Items = append(p.Items, item.Widget.Title)
@@ -117,25 +367,25 @@ func parsePages(root int, parentMapping map[int][]database.Item) (database.Apps,
 
 				f := database.AppFolder{Name: item.Group.Title}
 
-				if len(parentMapping[item.ID]) < 1 {
+				if len(item.Pages) < 1 {
 					return database.Apps{}, errors.New("did not find folder page item in page")
 				}
 
-				for fpIndex, fpage := range parentMapping[item.ID] {
+				for fpIndex, fpage := range item.Pages {
 					utils.Indent(log.WithField("number", fpIndex+1).Info, 3)("found folder page")
 
 					fp := database.FolderPage{Number: fpIndex + 1}
 
-					for _, folder := range parentMapping[fpage.ID] {
+					for _, folder := range fpage.Apps {
 						utils.Indent(log.WithField("title", folder.App.Title).Info, 4)("found app")
-						fp.Items = append(fp.Items, folder.App.Title)
+						fp.Items = append(fp.Items, ref(folder.App))
 					}
 
 					f.Pages = append(f.Pages, fp)
 				}
 
 				if len(f.Pages) > 0 && len(f.Pages[0].Items) > 0 {
-					p.Items = append(p.Items, f)
+					p.Items = append(p.Items, database.FolderItem(f))
 				} else {
 					utils.Indent(log.WithField("folder", item.Group.Title).Error, 3)("empty folder")
 				}
@@ -151,72 +401,14 @@ func parsePages(root int, parentMapping map[int][]database.Item) (database.Apps,
 	return apps, nil
 }
 
-// DefaultOrg will organize your launchpad by the app default categories
-func DefaultOrg(c *Config) (err error) {
-	var lpad database.LaunchPad
-
-	log.Infof(bold, "USING DEFAULT LAUNCHPAD ORGANIZATION")
-
-	// find launchpad database
-	tmpDir := os.Getenv("TMPDIR")
-	lpad.Folder = filepath.Join(tmpDir, "../0/com.apple.dock.launchpad/db")
-	lpad.File = filepath.Join(lpad.Folder, "db")
-	// lpad.File = "./launchpad.db"
-	if _, err := os.Stat(lpad.File); os.IsNotExist(err) {
-		utils.Indent(log.WithError(err).WithField("path", lpad.File).Fatal, 2)("launchpad DB not found")
-	}
-	utils.Indent(log.WithFields(log.Fields{"database": lpad.File}).Info, 2)("found launchpad database")
-
-	// start from a clean slate
-	err = removeOldDatabaseFiles(lpad.Folder)
-	if err != nil {
-		return err
-	}
-
-	// open launchpad database
-	lpad.DB, err = gorm.Open(sqlite.Open(lpad.File), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.LogLevel(c.LogLevel)),
-	})
-	if err != nil {
-		return err
-	}
-	defer func() {
-		db, err := lpad.DB.DB()
-		if err != nil {
-			err = errors.Wrap(err, "unable to get db when trying to close")
-		}
-		err = db.Close()
-		if err != nil {
-			err = errors.Wrap(err, "unable to close db")
-		}
-	}()
-
-	// Clear all items related to groups so we can re-create them
-	if err := lpad.ClearGroups(); err != nil {
-		return fmt.Errorf("failed to ClearGroups: %v", err)
-	}
-
-	// Disable the update triggers
-	if err := lpad.DisableTriggers(); err != nil {
-		return fmt.Errorf("failed to DisableTriggers: %v", err)
-	}
-
-	// Add root and holding pages to items and groups
-	if err := lpad.AddRootsAndHoldingPages(); err != nil {
-		return fmt.Errorf("failed to AddRootsAndHoldingPagesfailed: %v", err)
-	}
-
-	// We will begin our group records using the max ids found (groups always appear after apps and widgets)
-	// groupID := int(math.Max(float64(lpad.GetMaxAppID()), float64(lpad.GetMaxWidgetID())))
-	groupID := int(float64(lpad.GetMaxAppID())) // widgets are no longer supported
-
-	utils.Indent(log.Info, 2)("creating folders out of app categories")
-
-	// Create default config file
+// defaultConfig creates a config with a folder for each of the default app categories
+func defaultConfig(lpad *database.LaunchPad, grid database.Grid) database.Config {
 	var apps []database.App
 	var categories []database.Category
 	var config database.Config
 
+	utils.Indent(log.Info, 2)("creating folders out of app categories")
+
 	page := database.Page{Number: 1}
 
 	if err := lpad.DB.Find(&categories).Error; err != nil {
@@ -227,178 +419,282 @@ func DefaultOrg(c *Config) (err error) {
 		folderName := strings.Title(strings.Replace(strings.TrimPrefix(category.UTI, "public.app-category."), "-", " ", 1))
 		folder := database.AppFolder{Name: folderName}
 		folderPage := database.FolderPage{Number: 1}
-		utils.Indent(log.WithField("folder", folderName).Info, 3)("adding folder")
 		if err := lpad.DB.Where("category_id = ?", category.ID).Find(&apps).Error; err != nil {
 			log.WithError(err).Error("categories query failed")
 		}
+		if len(apps) == 0 {
+			continue
+		}
+		utils.Indent(log.WithField("folder", folderName).Info, 3)("adding folder")
 		for _, app := range apps {
 			utils.Indent(log.WithField("app", app.Title).Info, 4)("adding app to category folder")
-			folderPage.Items = utils.AppendIfMissing(folderPage.Items, app.Title)
+			if !slices.Contains(folderPage.Items, app.Ref()) {
+				folderPage.Items = append(folderPage.Items, app.Ref())
+			}
 		}
 		folder.Pages = append(folder.Pages, folderPage)
-		page.Items = append(page.Items, folder)
+		page.Items = append(page.Items, database.FolderItem(folder))
 	}
 	if err := lpad.DB.Where("category_id IS NULL").Find(&apps).Error; err != nil {
 		log.WithError(err).Error("categories query failed")
 	}
 	if len(apps) > 0 {
 		folder := database.AppFolder{Name: "Misc"}
-		for idx, appPage := range split(apps, 35) {
+		for idx, appPage := range split(apps, grid.FolderCapacity()) {
 			folderPage := database.FolderPage{Number: idx + 1}
 			for _, app := range appPage {
 				utils.Indent(log.WithField("app", app.Title).Info, 4)("adding app to Misc folder")
-				folderPage.Items = utils.AppendIfMissing(folderPage.Items, app.Title)
+				if !slices.Contains(folderPage.Items, app.Ref()) {
+					folderPage.Items = append(folderPage.Items, app.Ref())
+				}
 			}
 			folder.Pages = append(folder.Pages, folderPage)
 		}
-		page.Items = append(page.Items, folder)
+		page.Items = append(page.Items, database.FolderItem(folder))
 	}
 
 	config.Apps.Pages = append(config.Apps.Pages, page)
+	config.Grid = grid
+
+	return config
+}
+
+// resolveApps drops the apps that are not installed from lpad.Config, places the installed
+// apps it does not list and paginates the result
+func resolveApps(lpad *database.LaunchPad) (*database.MissingReport, error) {
+	apps, report, err := lpad.GetMissing(lpad.Config.Apps, database.ApplicationType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to GetMissing=>Apps: %v", err)
+	}
+	lpad.Config.Apps = apps
+
+	if err := lpad.Config.Apps.Paginate(lpad.Config.Grid); err != nil {
+		return nil, fmt.Errorf("failed to paginate apps: %v", err)
+	}
+
+	if err := lpad.Config.Verify(); err != nil {
+		return nil, fmt.Errorf("failed to verify conf post removal of missing apps: %v", err)
+	}
+
+	return report, nil
+}
+
+// rebuild clears out the launchpad layout and re-creates it from lpad.Config and
+// reports where the config and the installed apps disagree
+func rebuild(lpad *database.LaunchPad) (*database.MissingReport, error) {
+	// Clear all items related to groups so we can re-create them
+	if err := lpad.ClearGroups(); err != nil {
+		return nil, fmt.Errorf("failed to ClearGroups: %v", err)
+	}
+
+	// Disable the update triggers
+	if err := lpad.DisableTriggers(); err != nil {
+		return nil, fmt.Errorf("failed to DisableTriggers: %v", err)
+	}
+
+	// Add root and holding pages to items and groups
+	if err := lpad.AddRootsAndHoldingPages(); err != nil {
+		return nil, fmt.Errorf("failed to AddRootsAndHoldingPagesfailed: %v", err)
+	}
+
+	// We will begin our group records using the max ids found (groups always appear after apps and widgets)
+	// groupID := int(math.Max(float64(lpad.GetMaxAppID()), float64(lpad.GetMaxWidgetID())))
+	groupID := int(float64(lpad.GetMaxAppID())) // widgets are no longer supported
 
 	////////////////////////////////////////////////////////////////////
 	// Place Widgets ///////////////////////////////////////////////////
 	// utils.Indent(log.Info)("creating Widget folders and adding widgets to them")
-	// missing, err := lpad.GetMissing(conf.Widgets, database.WidgetType)
+	// missing, err := lpad.GetMissing(config.Widgets, database.WidgetType)
 	// if err != nil {
-	// 	log.WithError(err).Fatal("Default GetMissing=>Widgets")
+	// 	log.WithError(err).Fatal("GetMissing=>Widgets")
 	// }
 
-	// conf.Widgets.Pages = parseMissing(missing, conf.Widgets.Pages)
-	// groupID, err = lpad.ApplyConfig(conf.Widgets, database.WidgetType, groupID, 3)
+	// config.Widgets.Pages = parseMissing(missing, config.Widgets.Pages)
+	// groupID, err = lpad.ApplyConfig(config.Widgets, database.WidgetType, groupID, 3)
 	// if err != nil {
-	// 	log.WithError(err).Fatal("Default ApplyConfig=>Widgets")
+	// 	log.WithError(err).Fatal("ApplyConfig=>Widgets")
 	// }
 
 	/////////////////////////////////////////////////////////////////////
 	// Place Apps ///////////////////////////////////////////////////////
-	if err := lpad.GetMissing(&config.Apps, database.ApplicationType); err != nil {
-		return fmt.Errorf("failed to GetMissing=>Apps: %v", err)
+	report, err := resolveApps(lpad)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := lpad.Config.Verify(); err != nil {
-		return fmt.Errorf("failed to verify conf post removal of missing apps: %v", err)
+	utils.Indent(log.Info, 2)("creating App folders and adding apps to them")
+	if err := lpad.ApplyConfig(lpad.Config.Apps, groupID, 1); err != nil {
+		return nil, fmt.Errorf("failed to ApplyConfig: %w", err)
 	}
 
-	utils.Indent(log.Info, 2)("creating App folders and adding apps to them")
-	if err := lpad.ApplyConfig(config.Apps, groupID, 1); err != nil {
-		return fmt.Errorf("failed to DefaultOrg->ApplyConfig: %w", err)
+	// Re-enable the update triggers
+	if err := lpad.EnableTriggers(); err != nil {
+		return nil, fmt.Errorf("failed to EnableTriggers: %v", err)
 	}
 
+	return report, nil
+}
+
+// reconcile updates the launchpad layout in place to match lpad.Config, keeping the
+// existing pages and folders, and reports where the config and the installed apps disagree
+func reconcile(lpad *database.LaunchPad) (*database.ReconcileStats, *database.MissingReport, error) {
+	report, err := resolveApps(lpad)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Disable the update triggers
+	if err := lpad.DisableTriggers(); err != nil {
+		return nil, nil, fmt.Errorf("failed to DisableTriggers: %v", err)
+	}
+
+	utils.Indent(log.Info, 2)("reconciling App folders and pages with the config")
+	stats, err := lpad.Reconcile(lpad.Config.Apps)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to Reconcile: %w", err)
+	}
+	utils.Indent(log.Info, 3)(stats.String())
+
 	// Re-enable the update triggers
 	if err := lpad.EnableTriggers(); err != nil {
-		return fmt.Errorf("failed to EnableTriggers: %v", err)
+		return nil, nil, fmt.Errorf("failed to EnableTriggers: %v", err)
 	}
 
-	return restartDock()
+	return stats, report, nil
+}
+
+// DefaultOrg will organize your launchpad by the app default categories
+func DefaultOrg(c *Config) (err error) {
+	unlock, err := c.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	j, err := c.beginJournal(stepLayout, stepRestart)
+	if err != nil {
+		return err
+	}
+	defer func() { j.end(err) }()
+
+	log.Infof(bold, "USING DEFAULT LAUNCHPAD ORGANIZATION")
+
+	lpad, err := c.openLaunchPad(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer lpad.Close()
+
+	lpad.Config = defaultConfig(lpad, c.resolveGrid(database.Grid{}))
+	lpad.Config.Missing = c.resolveMissing(database.Placement{})
+
+	// Rebuild the layout in a single transaction so any failure rolls back every change
+	// it made, including the disabled update triggers. The reset of the live database
+	// before it is not undone.
+	lpad.DB = lpad.DB.WithContext(ctx)
+	if err := lpad.Transaction(func() error {
+		_, err := rebuild(lpad)
+		return err
+	}); err != nil {
+		if ctx.Err() != nil {
+			err = ErrInterrupted
+		}
+		return fmt.Errorf("failed to apply default organization (%s): %w", c.rolledBack(true), err)
+	}
+	if err := j.done(stepLayout); err != nil {
+		return err
+	}
+
+	return c.finish(ctx, j, lpad)
+}
+
+// PlanDefault computes the changes DefaultOrg would make without writing them
+func PlanDefault(c *Config) (*Plan, error) {
+	lpad, cleanup, err := c.openScratch(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	lpad.Config = defaultConfig(lpad, c.resolveGrid(database.Grid{}))
+	lpad.Config.Missing = c.resolveMissing(database.Placement{})
+
+	return plan(lpad, nil, false)
 }
 
 // SaveConfig will save your launchpad settings to a config file
 func SaveConfig(c *Config) (err error) {
-	var (
-		lpad          database.LaunchPad
-		launchpadRoot int
-		dashboardRoot int
-		items         []database.Item
-		dbinfo        []database.DBInfo
-		conf          database.Config
-	)
+	unlock, err := c.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	log.Infof(bold, "SAVING LAUNCHPAD DATABASE")
 
-	home, err := os.UserHomeDir()
+	conf, err := c.readConfig(context.Background())
 	if err != nil {
-		return fmt.Errorf("failed to get user home directory: %v", err)
+		return err
 	}
 
-	// find launchpad database
-	tmpDir := os.Getenv("TMPDIR")
-	lpad.Folder = filepath.Join(tmpDir, "../0/com.apple.dock.launchpad/db")
-	lpad.File = filepath.Join(lpad.Folder, "db")
-	// lpad.File = "./launchpad.db"
-	if _, err := os.Stat(lpad.File); os.IsNotExist(err) {
-		utils.Indent(log.WithError(err).WithField("path", lpad.File).Fatal, 2)("launchpad DB not found")
+	if err := writeConfig(c.File, conf); err != nil {
+		return err
 	}
-	utils.Indent(log.WithFields(log.Fields{"database": lpad.File}).Info, 2)("found launchpad database")
 
-	// open launchpad database
-	lpad.DB, err = gorm.Open(sqlite.Open(lpad.File), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.LogLevel(c.LogLevel)),
-	})
+	log.Infof(bold, "successfully wrote settings to: "+c.File)
+
+	return nil
+}
+
+// readConfig reads the current launchpad layout and Dock settings into a config
+func (c *Config) readConfig(ctx context.Context) (*database.Config, error) {
+	var conf database.Config
+
+	home, err := os.UserHomeDir()
 	if err != nil {
-		return err
-	}
-	defer func() {
-		db, err := lpad.DB.DB()
-		if err != nil {
-			err = errors.Wrap(err, "unable to get db when trying to close")
-		}
-		err = db.Close()
-		if err != nil {
-			err = errors.Wrap(err, "unable to close db")
-		}
-	}()
-
-	// get launchpad and dashboard roots
-	if err := lpad.DB.Where("key in (?)", []string{"launchpad_root", "dashboard_root"}).Find(&dbinfo).Error; err != nil {
-		log.WithError(err).Error("dbinfo query failed")
-	}
-	for _, info := range dbinfo {
-		switch info.Key {
-		case "launchpad_root":
-			launchpadRoot, _ = strconv.Atoi(info.Value)
-		case "dashboard_root":
-			dashboardRoot, _ = strconv.Atoi(info.Value)
-		default:
-			log.WithField("key", info.Key).Error("bad key")
-		}
+		return nil, fmt.Errorf("failed to get user home directory: %v", err)
 	}
 
-	// get all the relavent items
-	if err := lpad.DB.Not("uuid in (?)", []string{"ROOTPAGE", "HOLDINGPAGE", "ROOTPAGE_DB", "HOLDINGPAGE_DB", "ROOTPAGE_VERS", "HOLDINGPAGE_VERS"}).
-		Order("items.parent_id, items.ordering").
-		Find(&items).Error; err != nil {
-		log.WithError(err).Error("items query failed")
+	// read from a snapshot as the Dock may be writing to the database
+	lpad, closeSnapshot, err := c.openSnapshot(ctx)
+	if err != nil {
+		return nil, err
 	}
+	defer closeSnapshot()
 
-	// create parent mapping object
+	// read the launchpad and dashboard layout
 	log.Info("collecting launchpad/dashboard pages")
-	parentMapping := make(map[int][]database.Item)
-	for _, item := range items {
-		lpad.DB.Model(&item).Association("App").Find(&item.App)
-		// db.Model(&item).Related(&item.Widget)
-		lpad.DB.Model(&item).Association("Group").Find(&item.Group)
-
-		parentMapping[item.ParentID] = append(parentMapping[item.ParentID], item)
+	layout, err := lpad.ReadLayout()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read launchpad layout")
 	}
 
 	log.Info("interating over launchpad pages")
-	conf.Apps, err = parsePages(launchpadRoot, parentMapping)
+	conf.Apps, err = parsePages(layout.Launchpad, c.BundleIDs)
 	if err != nil {
-		return errors.Wrap(err, "unable to parse launchpad pages")
+		return nil, errors.Wrap(err, "unable to parse launchpad pages")
 	}
 
 	log.Info("interating over dashboard pages")
-	conf.Widgets, err = parsePages(dashboardRoot, parentMapping)
+	conf.Widgets, err = parsePages(layout.Dashboard, c.BundleIDs)
 	if err != nil {
-		return errors.Wrap(err, "unable to parse dashboard pages")
+		return nil, errors.Wrap(err, "unable to parse dashboard pages")
 	}
 
 	log.Info("interating over dock apps")
-	dPlist, err := dock.LoadDockPlist()
+	dPlist, err := c.loadDockPlist()
 	if err != nil {
-		return errors.Wrap(err, "unable to load dock plist")
+		return nil, errors.Wrap(err, "unable to load dock plist")
 	}
 	for _, item := range dPlist.PersistentApps {
 		conf.Dock.Apps = append(conf.Dock.Apps, item.TileData.GetPath())
 	}
 	for _, item := range dPlist.PersistentOthers {
-		abspath := item.TileData.GetPath()
-		if relPath, err := filepath.Rel(home, abspath); err == nil {
-			abspath = filepath.Join("~", relPath)
-		}
 		conf.Dock.Others = append(conf.Dock.Others, database.Folder{
-			Path:    abspath,
+			Path:    homeRelPath(home, item.TileData.GetPath()),
 			Display: database.FolderDisplay(item.TileData.DisplayAs),
 			View:    database.FolderView(item.TileData.ShowAs),
 			Sort:    database.FolderSort(item.TileData.Arrangement),
@@ -414,11 +710,12 @@ func SaveConfig(c *Config) (err error) {
 		TileSize:              dPlist.TileSize,
 	}
 
-	if c.Backup {
-		c.File += ".bak"
-	}
+	return &conf, nil
+}
 
-	f, err := os.Create(c.File)
+// writeConfig writes the config to a YAML file
+func writeConfig(path string, conf *database.Config) error {
+	f, err := os.Create(path)
 	if err != nil {
 		return fmt.Errorf("failed to create config file: %w", err)
 	}
@@ -427,169 +724,186 @@ func SaveConfig(c *Config) (err error) {
 	// write out config YAML file
 	enc := yaml.NewEncoder(f)
 	enc.SetIndent(2)
-	if err := enc.Encode(&conf); err != nil {
+	if err := enc.Encode(conf); err != nil {
 		return errors.Wrap(err, "unable to marshall YAML")
 	}
 	if err := enc.Close(); err != nil {
 		return errors.Wrap(err, "unable to close YAML encoder")
 	}
 
-	if c.Backup {
-		log.Infof(bold, "successfully backed up current settings to: "+c.File)
-	} else {
-		log.Infof(bold, "successfully wrote settings to: "+c.File)
-	}
-
 	return nil
 }
 
 // LoadConfig will load your launchpad settings from a config file
 func LoadConfig(c *Config) (err error) {
-	var lpad database.LaunchPad
+	unlock, err := c.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	// Read in Config file
-	lpad.Config, err = database.LoadConfig(c.File)
+	config, err := database.LoadConfig(c.File)
 	if err != nil {
-		return fmt.Errorf("failed to load config file: %v", err)
+		return fmt.Errorf("failed to load config file: %w", err)
 	}
 
-	log.Infof(bold, "PARSE LAUCHPAD DATABASE")
+	ctx, stop := interruptContext()
+	defer stop()
+
+	j, err := c.beginJournal(stepLayout, stepRestart, stepFixOther, stepDesktop, stepDock)
+	if err != nil {
+		return err
+	}
+	defer func() { j.end(err) }()
 
-	// Older macOS ////////////////////////////////
-	// $HOME/Library/Application\ Support/Dock/*.db
+	log.Infof(bold, "PARSE LAUCHPAD DATABASE")
 
-	// High Sierra //////////////////////////////
-	// $TMPDIR../0/com.apple.dock.launchpad/db/db
+	config.Grid = c.resolveGrid(config.Grid)
+	config.Missing = c.resolveMissing(config.Missing)
 
-	// find launchpad database
-	tmpDir := os.Getenv("TMPDIR")
-	lpad.Folder = filepath.Join(tmpDir, "../0/com.apple.dock.launchpad/db")
-	lpad.File = filepath.Join(lpad.Folder, "db")
-	// lpad.File = "./launchpad-test.db"
-	if _, err := os.Stat(lpad.File); os.IsNotExist(err) {
-		utils.Indent(log.WithError(err).WithField("path", lpad.File).Fatal, 2)("launchpad DB not found")
+	// an incremental load edits the existing database so it is never reset
+	reset := !c.Incremental
+	if reset && c.Strict && c.LiveDB() && !c.NoRestart {
+		if err := c.checkStrictBeforeReset(ctx, config); err != nil {
+			return fmt.Errorf("failed to load config (nothing was changed): %w", err)
+		}
 	}
-	utils.Indent(log.WithFields(log.Fields{"database": lpad.File}).Info, 2)("found launchpad database")
 
-	// start from a clean slate
-	err = removeOldDatabaseFiles(lpad.Folder)
+	lpad, err := c.openLaunchPad(ctx, reset)
 	if err != nil {
 		return err
 	}
+	defer lpad.Close()
 
-	// open launchpad database
-	lpad.DB, err = gorm.Open(sqlite.Open(lpad.File), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.LogLevel(c.LogLevel)),
-	})
-	if err != nil {
-		return err
-	}
-	defer func() {
-		db, err := lpad.DB.DB()
-		if err != nil {
-			err = errors.Wrap(err, "unable to get db when trying to close")
+	lpad.Config = config
+
+	// Rebuild or reconcile the layout in a single transaction so any failure rolls
+	// back every change it made, including the disabled update triggers. Ctrl-C cancels
+	// the queries, which rolls the transaction back too. The reset of the live database
+	// before it is not undone.
+	lpad.DB = lpad.DB.WithContext(ctx)
+	if err := lpad.Transaction(func() error {
+		var report *database.MissingReport
+		if c.Incremental {
+			_, report, err = reconcile(lpad)
+		} else {
+			report, err = rebuild(lpad)
 		}
-		err = db.Close()
 		if err != nil {
-			err = errors.Wrap(err, "unable to close db")
+			return err
 		}
-	}()
-
-	// Clear all items related to groups so we can re-create them
-	if err := lpad.ClearGroups(); err != nil {
-		return fmt.Errorf("failed to ClearGroups: %v", err)
-	}
-
-	// Disable the update triggers
-	if err := lpad.DisableTriggers(); err != nil {
-		return fmt.Errorf("failed to DisableTriggers: %v", err)
+		return c.CheckStrict(report)
+	}); err != nil {
+		if ctx.Err() != nil {
+			err = ErrInterrupted
+		}
+		return fmt.Errorf("failed to load config (%s): %w", c.rolledBack(reset), err)
 	}
-
-	// Add root and holding pages to items and groups
-	if err := lpad.AddRootsAndHoldingPages(); err != nil {
-		return fmt.Errorf("failed to AddRootsAndHoldingPagesfailed: %v", err)
+	if err := j.done(stepLayout); err != nil {
+		return err
 	}
 
-	// We will begin our group records using the max ids found (groups always appear after apps and widgets)
-	// groupID := int(math.Max(float64(lpad.GetMaxAppID()), float64(lpad.GetMaxWidgetID())))
-	groupID := int(float64(lpad.GetMaxAppID())) // widgets are no longer supported
-
-	////////////////////////////////////////////////////////////////////
-	// Place Widgets ///////////////////////////////////////////////////
-	// utils.Indent(log.Info)("creating Widget folders and adding widgets to them")
-	// missing, err := lpad.GetMissing(config.Widgets, database.WidgetType)
-	// if err != nil {
-	// 	log.WithError(err).Fatal("GetMissing=>Widgets")
-	// }
-
-	// config.Widgets.Pages = parseMissing(missing, config.Widgets.Pages)
-	// groupID, err = lpad.ApplyConfig(config.Widgets, database.WidgetType, groupID, 3)
-	// if err != nil {
-	// 	log.WithError(err).Fatal("ApplyConfig=>Widgets")
-	// }
+	return c.finish(ctx, j, lpad)
+}
 
-	/////////////////////////////////////////////////////////////////////
-	// Place Apps ///////////////////////////////////////////////////////
-	if err := lpad.GetMissing(&lpad.Config.Apps, database.ApplicationType); err != nil {
-		return fmt.Errorf("failed to GetMissing=>Apps: %v", err)
+// runStep runs one of the steps after the layout was written
+func (c *Config) runStep(ctx context.Context, step string, lpad *database.LaunchPad) error {
+	switch step {
+	case stepRestart:
+		if c.NoRestart {
+			utils.Indent(log.Warn, 2)("skipping Dock restart")
+			return nil
+		}
+		if err := c.restartDock(ctx); err != nil {
+			return err
+		}
+	case stepFixOther:
+		if c.NoRestart {
+			// the Dock only moves orphaned apps into 'Other' when it restarts
+			utils.Indent(log.Warn, 2)("skipping Other folder fix")
+			return nil
+		}
+		if err := lpad.Transaction(lpad.FixOther); err != nil {
+			return fmt.Errorf("failed to fix Other folder: %w", err)
+		}
+	case stepDesktop:
+		if len(lpad.Config.Desktop.Image) == 0 {
+			return nil
+		}
+		if c.NoRestart {
+			utils.Indent(log.WithField("image", lpad.Config.Desktop.Image).Warn, 2)("skipping desktop background image")
+		} else {
+			utils.Indent(log.WithField("image", lpad.Config.Desktop.Image).Info, 2)("setting desktop background image")
+			desktop.SetDesktopImage(ctx, lpad.Config.Desktop.Image)
+		}
+	case stepDock:
+		if len(lpad.Config.Dock.Apps) == 0 && len(lpad.Config.Dock.Others) == 0 {
+			return nil
+		}
+		return c.applyDock(ctx, lpad.Config.Dock)
+	default:
+		return fmt.Errorf("unknown step '%s'", step)
 	}
+	return nil
+}
 
-	if err := lpad.Config.Verify(); err != nil {
-		return fmt.Errorf("failed to verify conf post removal of missing apps: %v", err)
+// applyDock replaces the Dock apps and folders and applies the Dock settings
+func (c *Config) applyDock(ctx context.Context, conf database.Dock) error {
+	utils.Indent(log.Info, 2)("setting dock apps")
+	dPlist, err := c.loadDockPlist()
+	if err != nil {
+		return errors.Wrap(err, "unable to load dock plist")
 	}
-
-	utils.Indent(log.Info, 2)("creating App folders and adding apps to them")
-	if err := lpad.ApplyConfig(lpad.Config.Apps, groupID, 1); err != nil {
-		return fmt.Errorf("failed to LoadConfig->ApplyConfig: %w", err)
+	if len(dPlist.PersistentApps) > 0 {
+		dPlist.PersistentApps = nil // remove all apps from dock
 	}
-
-	// Re-enable the update triggers
-	if err := lpad.EnableTriggers(); err != nil {
-		return fmt.Errorf("failed to EnableTriggers: %v", err)
+	for _, app := range conf.Apps {
+		utils.Indent(log.WithField("app", app).Info, 3)("adding to dock")
+		dPlist.AddApp(app)
 	}
-
-	if err := restartDock(); err != nil {
-		return fmt.Errorf("failed to restart dock: %w", err)
+	if len(dPlist.PersistentOthers) > 0 {
+		dPlist.PersistentOthers = nil // remove all folders from dock
 	}
+	for _, other := range conf.Others {
+		utils.Indent(log.WithField("other", other).Info, 3)("adding to dock")
+		dPlist.AddOther(other)
+	}
+	if conf.Settings != nil {
+		if err := dPlist.ApplySettings(*conf.Settings); err != nil {
+			return fmt.Errorf("failed to apply dock settings: %w", err)
+		}
+	}
+	if err := c.saveDockPlist(ctx, dPlist); err != nil {
+		return fmt.Errorf("failed to save dock plist: %w", err)
+	}
+	return nil
+}
 
-	if err := lpad.FixOther(); err != nil {
-		return fmt.Errorf("failed to fix Other folder: %w", err)
+// PlanLoad computes the changes LoadConfig would make without writing them
+func PlanLoad(c *Config) (*Plan, error) {
+	config, err := database.LoadConfig(c.File)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config file: %w", err)
 	}
 
-	if len(lpad.Config.Desktop.Image) > 0 {
-		utils.Indent(log.WithField("image", lpad.Config.Desktop.Image).Info, 2)("setting desktop background image")
-		desktop.SetDesktopImage(lpad.Config.Desktop.Image)
+	lpad, cleanup, err := c.openScratch(context.Background())
+	if err != nil {
+		return nil, err
 	}
+	defer cleanup()
+
+	lpad.Config = config
+	lpad.Config.Grid = c.resolveGrid(config.Grid)
+	lpad.Config.Missing = c.resolveMissing(config.Missing)
 
-	if len(lpad.Config.Dock.Apps) > 0 || len(lpad.Config.Dock.Others) > 0 {
-		utils.Indent(log.Info, 2)("setting dock apps")
-		dPlist, err := dock.LoadDockPlist()
+	var dPlist *dock.Plist
+	if len(config.Dock.Apps) > 0 || len(config.Dock.Others) > 0 {
+		dPlist, err = c.loadDockPlist()
 		if err != nil {
-			return errors.Wrap(err, "unable to load dock plist")
-		}
-		if len(dPlist.PersistentApps) > 0 {
-			dPlist.PersistentApps = nil // remove all apps from dock
-		}
-		for _, app := range lpad.Config.Dock.Apps {
-			utils.Indent(log.WithField("app", app).Info, 3)("adding to dock")
-			dPlist.AddApp(app)
-		}
-		if len(dPlist.PersistentOthers) > 0 {
-			dPlist.PersistentOthers = nil // remove all folders from dock
-		}
-		for _, other := range lpad.Config.Dock.Others {
-			utils.Indent(log.WithField("other", other).Info, 3)("adding to dock")
-			dPlist.AddOther(other)
-		}
-		if lpad.Config.Dock.Settings != nil {
-			if err := dPlist.ApplySettings(*lpad.Config.Dock.Settings); err != nil {
-				return fmt.Errorf("failed to apply dock settings: %w", err)
-			}
-		}
-		if err := dPlist.Save(); err != nil {
-			return fmt.Errorf("failed to save dock plist: %w", err)
+			return nil, errors.Wrap(err, "unable to load dock plist")
 		}
 	}
 
-	return nil
+	return plan(lpad, dPlist, c.Incremental)
 }
@@ -0,0 +1,129 @@
+package command
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/blacktop/lporg/internal/database"
+	"github.com/blacktop/lporg/internal/database/testdb"
+	"github.com/blacktop/lporg/internal/runner/runnertest"
+)
+
+func TestConfig_CheckStrict(t *testing.T) {
+	log.SetLevel(log.FatalLevel)
+
+	tests := []struct {
+		name    string
+		strict  bool
+		report  database.MissingReport
+		wantErr []error
+		notErr  []error
+	}{
+		{name: "not strict", report: database.MissingReport{Unlisted: []string{"Chess"}, Uninstalled: []string{"Xcode"}}},
+		{name: "empty report", strict: true},
+		{name: "unlisted", strict: true, report: database.MissingReport{Unlisted: []string{"Chess"}}, wantErr: []error{ErrStrict}, notErr: []error{database.ErrAppNotInstalled}},
+		{name: "empty folder", strict: true, report: database.MissingReport{EmptyFolders: []string{"Games"}}, wantErr: []error{ErrStrict}, notErr: []error{database.ErrAppNotInstalled}},
+		{name: "uninstalled", strict: true, report: database.MissingReport{Uninstalled: []string{"Xcode"}}, wantErr: []error{ErrStrict, database.ErrAppNotInstalled}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{Strict: tt.strict}
+			err := c.CheckStrict(&tt.report)
+			if len(tt.wantErr) == 0 && err != nil {
+				t.Fatalf("CheckStrict() error = %v", err)
+			}
+			for _, want := range tt.wantErr {
+				if !errors.Is(err, want) {
+					t.Errorf("CheckStrict() error = %v, want %v", err, want)
+				}
+			}
+			for _, not := range tt.notErr {
+				if errors.Is(err, not) {
+					t.Errorf("CheckStrict() error = %v, want not %v", err, not)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfig_refusedBeforeReset(t *testing.T) {
+	tests := []struct {
+		name    string
+		alter   string // SQL run on the live database first
+		items   string // the config's first page
+		strict  bool
+		wantErr []error
+	}{
+		{name: "unknown schema", alter: "DROP TRIGGER insert_item;", items: "[App 0000, App 0001, App 0002]", wantErr: []error{database.ErrUnknownSchema}},
+		{name: "strict", items: "[App 0000, App 0001, App 0002, Xcode]", strict: true, wantErr: []error{ErrStrict, database.ErrAppNotInstalled}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log.SetLevel(log.FatalLevel)
+			home := setConfigDir(t)
+			db := liveDB(t, testdb.Generate(3, 35))
+			if len(tt.alter) > 0 {
+				execSQL(t, db, tt.alter)
+			}
+			live, err := os.ReadFile(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			config := filepath.Join(home, "lporg.yml")
+			if err := os.WriteFile(config, []byte("apps:\n  pages:\n    - number: 1\n      items: "+tt.items+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			fake := fakeDock(t, runnertest.Result{}, 100, 200)
+
+			c := &Config{Cmd: "load", File: config, Strict: tt.strict, DockTimeout: 100 * time.Millisecond}
+			err = LoadConfig(c)
+			for _, want := range tt.wantErr {
+				if !errors.Is(err, want) {
+					t.Fatalf("LoadConfig() error = %v, want %v", err, want)
+				}
+			}
+			for _, cmd := range fake.Calls() {
+				if cmd.Name == "killall" {
+					t.Errorf("LoadConfig() restarted the Dock before refusing")
+				}
+			}
+			after, err := os.ReadFile(db)
+			if err != nil {
+				t.Fatalf("LoadConfig() removed the live database: %v", err)
+			}
+			if !bytes.Equal(after, live) {
+				t.Errorf("LoadConfig() changed the live database")
+			}
+		})
+	}
+}
+
+func TestConfig_rolledBack(t *testing.T) {
+	tests := []struct {
+		name      string
+		locator   Locator
+		noRestart bool
+		reset     bool
+		wantReset bool
+	}{
+		{name: "live reset", reset: true, wantReset: true},
+		{name: "incremental", reset: false},
+		{name: "no restart", reset: true, noRestart: true},
+		{name: "offline", reset: true, locator: Locator{DB: "db"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{Locator: tt.locator, NoRestart: tt.noRestart}
+			got := c.rolledBack(tt.reset)
+			if strings.Contains(got, "already reset") != tt.wantReset {
+				t.Errorf("rolledBack() = %q, want reset mentioned %v", got, tt.wantReset)
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package command
+
+import "errors"
+
+var (
+	// ErrInterrupted is returned when a run is stopped by Ctrl-C or SIGTERM
+	ErrInterrupted = errors.New("interrupted")
+	// ErrDockRestart is returned when the Dock could not be restarted
+	ErrDockRestart = errors.New("failed to restart the Dock")
+	// ErrLocked is returned when another lporg run holds the lock
+	ErrLocked = errors.New("another lporg run is in progress")
+	// ErrStrict is returned with --strict when the config and the installed apps disagree
+	ErrStrict = errors.New("config and installed apps disagree")
+)
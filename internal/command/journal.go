@@ -0,0 +1,294 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/blacktop/lporg/internal/database"
+	"github.com/blacktop/lporg/internal/utils"
+	"golang.org/x/exp/slices"
+)
+
+// Steps of a load or default run. Everything before stepLayout is done in a single
+// transaction so an interrupted run either wrote the whole layout or nothing.
+const (
+	stepLayout   = "layout"
+	stepRestart  = "restart"
+	stepFixOther = "fix-other"
+	stepDesktop  = "desktop"
+	stepDock     = "dock"
+)
+
+const journalFile = "journal.json"
+
+// configSteps are the steps that apply parts of the config besides the layout
+var configSteps = []string{stepDesktop, stepDock}
+
+// needsConfig returns true if any of steps applies the config
+func needsConfig(steps []string) bool {
+	return slices.ContainsFunc(steps, func(step string) bool {
+		return slices.Contains(configSteps, step)
+	})
+}
+
+// interruptContext returns a context that is cancelled by Ctrl-C or SIGTERM
+var interruptContext = func() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// journal records a load or default in progress so the next invocation can detect
+// a run that was interrupted or crashed and finish it
+type journal struct {
+	path string
+
+	PID       int       `json:"pid"`
+	Command   string    `json:"command"`
+	Started   time.Time `json:"started"`
+	DB        string    `json:"db,omitempty"`         // --db, empty for the live database
+	DockPlist string    `json:"dock_plist,omitempty"` // --dock-plist, empty for the live plist
+	Config    string    `json:"config,omitempty"`     // the config file the desktop and dock steps apply
+	NoRestart bool      `json:"no_restart,omitempty"`
+	Done      []string  `json:"done"`
+	Pending   []string  `json:"pending"`
+}
+
+// localDir returns the lporg config dir on this Mac, where state about runs in progress
+// is kept even with --icloud
+func localDir() (string, error) {
+	confDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user config dir: %w", err)
+	}
+	return filepath.Join(confDir, "lporg"), nil
+}
+
+// journalPath returns where the journal is kept. It is always local, even with --icloud.
+func journalPath() (string, error) {
+	dir, err := localDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, journalFile), nil
+}
+
+// beginJournal records the start of a run made of steps
+func (c *Config) beginJournal(steps ...string) (*journal, error) {
+	path, err := journalPath()
+	if err != nil {
+		return nil, err
+	}
+	j := &journal{
+		path:      path,
+		PID:       os.Getpid(),
+		Command:   c.Cmd,
+		Started:   time.Now(),
+		DB:        c.DB,
+		DockPlist: c.DockPlist,
+		NoRestart: c.NoRestart,
+		Done:      []string{},
+		Pending:   steps,
+	}
+	if len(c.File) > 0 && needsConfig(steps) {
+		if j.Config, err = filepath.Abs(c.File); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, fmt.Errorf("failed to create journal dir: %w", err)
+	}
+	return j, j.save()
+}
+
+// readJournal returns the journal left by a previous run or nil if there is none
+func readJournal() (*journal, error) {
+	path, err := journalPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+	j := &journal{path: path}
+	if err := json.Unmarshal(data, j); err != nil {
+		utils.Indent(log.WithError(err).WithField("journal", path).Warn, 2)("ignoring invalid journal")
+		return nil, os.Remove(path)
+	}
+	return j, nil
+}
+
+// save writes the journal to a temp file and renames it so it is never half written
+func (j *journal) save() error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0640); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	if err := os.Rename(tmp, j.path); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	return nil
+}
+
+// done records that step finished
+func (j *journal) done(step string) error {
+	j.Done = append(j.Done, step)
+	if idx := slices.Index(j.Pending, step); idx >= 0 {
+		j.Pending = slices.Delete(j.Pending, idx, idx+1)
+	}
+	return j.save()
+}
+
+// layoutWritten returns true if the layout transaction committed
+func (j *journal) layoutWritten() bool {
+	return slices.Contains(j.Done, stepLayout)
+}
+
+// remove deletes the journal
+func (j *journal) remove() error {
+	if err := os.Remove(j.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove journal: %w", err)
+	}
+	return nil
+}
+
+// end removes the journal unless the run failed after writing the layout, in which
+// case it is kept so the next invocation finishes the remaining steps
+func (j *journal) end(err error) {
+	if err != nil && j.layoutWritten() && len(j.Pending) > 0 {
+		utils.Indent(log.WithField("steps", j.Pending).Warn, 2)("the layout was written but the run did not finish, run lporg again to finish it")
+		return
+	}
+	if rerr := j.remove(); rerr != nil {
+		utils.Indent(log.WithError(rerr).Warn, 2)("failed to clean up")
+	}
+}
+
+// checkInterrupted returns ErrInterrupted if ctx was cancelled by a signal
+func checkInterrupted(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ErrInterrupted
+	}
+	return nil
+}
+
+// Recover detects a load or default that was interrupted or crashed and finishes it
+// if it got as far as recording the layout as written. Otherwise the layout transaction
+// was rolled back, or committed just before the run died and never recorded, so it
+// only makes sure the update triggers are back on and warns that the layout may not
+// have been applied.
+func Recover(c *Config) error {
+	unlock, err := c.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	j, err := readJournal()
+	if err != nil {
+		return err
+	}
+
+	if j == nil {
+		// older versions or other tools may have left the update triggers off
+		return c.recoverTriggers(ctx)
+	}
+
+	log.Infof(bold, "RECOVERING INTERRUPTED "+j.Command)
+
+	// recover the database and Dock the interrupted run was using
+	rc := &Config{
+		Locator:     Locator{DB: j.DB, DockPlist: j.DockPlist},
+		Cmd:         j.Command,
+		File:        j.Config,
+		NoRestart:   j.NoRestart,
+		BusyTimeout: c.BusyTimeout,
+		DockTimeout: c.DockTimeout,
+		LogLevel:    c.LogLevel,
+	}
+
+	if !j.layoutWritten() {
+		utils.Indent(log.WithField("started", j.Started.Format(time.RFC3339)).Warn, 2)("the interrupted run did not finish writing its layout, it may not have been applied (run it again to be sure)")
+		if err := rc.recoverTriggers(ctx); err != nil {
+			return err
+		}
+		return j.remove()
+	}
+
+	lpad, err := rc.openLaunchPad(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer lpad.Close()
+
+	if needsConfig(j.Pending) {
+		config, err := database.LoadConfig(rc.File)
+		if err != nil {
+			// the layout is written, so still restart the Dock and fix the Other folder
+			utils.Indent(log.WithError(err).Warn, 2)("unable to finish the desktop and dock steps, the config is gone")
+			j.Pending = slices.DeleteFunc(j.Pending, func(step string) bool {
+				return slices.Contains(configSteps, step)
+			})
+		} else {
+			lpad.Config = config
+		}
+	}
+
+	utils.Indent(log.WithField("steps", j.Pending).Info, 2)("finishing the interrupted run")
+	if err := rc.finish(ctx, j, lpad); err != nil {
+		return fmt.Errorf("failed to finish interrupted %s: %w", j.Command, err)
+	}
+	return j.remove()
+}
+
+// recoverTriggers turns the update triggers back on if a previous run left them off
+func (c *Config) recoverTriggers(ctx context.Context) error {
+	lpad, err := c.openLaunchPad(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer lpad.Close()
+
+	if !lpad.TriggersDisabled() {
+		return nil
+	}
+	utils.Indent(log.Warn, 2)("found the launchpad update triggers disabled by an interrupted run")
+	return lpad.Transaction(lpad.EnableTriggers)
+}
+
+// finish runs the pending steps after the layout was written
+func (c *Config) finish(ctx context.Context, j *journal, lpad *database.LaunchPad) error {
+	for len(j.Pending) > 0 {
+		if err := checkInterrupted(ctx); err != nil {
+			return err
+		}
+		step := j.Pending[0]
+		if err := c.runStep(ctx, step, lpad); err != nil {
+			if ctx.Err() != nil {
+				return ErrInterrupted
+			}
+			return err
+		}
+		if err := j.done(step); err != nil {
+			return err
+		}
+	}
+	return nil
+}
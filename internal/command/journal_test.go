@@ -0,0 +1,196 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/blacktop/lporg/internal/database"
+	"github.com/blacktop/lporg/internal/database/testdb"
+	"github.com/blacktop/lporg/internal/dock"
+	"github.com/blacktop/lporg/internal/runner/runnertest"
+	"github.com/glebarez/sqlite"
+	"golang.org/x/exp/slices"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// journalFixture creates a database and Dock plist in a temp HOME and returns a config for them
+func journalFixture(t *testing.T) *Config {
+	t.Helper()
+	log.SetLevel(log.ErrorLevel)
+
+	home := setConfigDir(t)
+	data, err := os.ReadFile(filepath.Join("..", "..", ".hack", "test", "com.apple.dock.plist"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plist := filepath.Join(home, "com.apple.dock.plist")
+	if err := os.WriteFile(plist, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := filepath.Join(home, "lporg.yml")
+	if err := os.WriteFile(config, []byte(`apps:
+  pages:
+    - number: 1
+      items:
+        - folder: Apps
+          pages:
+            - items: [App 0000, App 0001, App 0002]
+dock_items:
+  apps:
+    - /System/Applications/Mail.app
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return &Config{
+		Locator:   Locator{DB: testdb.New(t, testdb.Generate(3, 35)), DockPlist: plist},
+		Cmd:       "load",
+		File:      config,
+		NoRestart: true,
+	}
+}
+
+// triggersDisabled opens the database of c and returns whether its update triggers are off
+func triggersDisabled(t *testing.T, c *Config, disable bool) bool {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(c.DB), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lpad := &database.LaunchPad{DB: db}
+	defer lpad.Close()
+	if disable {
+		if err := lpad.DisableTriggers(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return lpad.TriggersDisabled()
+}
+
+func TestLoadConfig_interrupted(t *testing.T) {
+	c := journalFixture(t)
+
+	orig := interruptContext
+	t.Cleanup(func() { interruptContext = orig })
+	interruptContext = func() (context.Context, context.CancelFunc) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel() // Ctrl-C right away
+		return ctx, cancel
+	}
+
+	if err := LoadConfig(c); !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("LoadConfig() error = %v, want ErrInterrupted", err)
+	}
+	if j, err := readJournal(); err != nil || j != nil {
+		t.Errorf("readJournal() = %v, %v, want the journal of a rolled back run removed", j, err)
+	}
+	conf, err := c.readConfig(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conf.Apps.Pages) != 1 || conf.Apps.Pages[0].Items[0].Folder != nil {
+		t.Errorf("LoadConfig() changed the layout although it was interrupted: %v", conf.Apps.Pages)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	tests := []struct {
+		name         string
+		cmd          string   // the interrupted command, load if empty
+		steps        []string // its steps, all of load's if empty
+		done         []string // steps the interrupted run finished, nil for no journal
+		disable      bool     // leave the update triggers off
+		locked       bool     // the interrupted run is still going and holds the lock
+		restart      bool     // the interrupted run restarts the Dock
+		removeConfig bool     // the config is gone by the time of recovery
+		wantRestart  bool     // the pending restart step was finished
+		wantDock     bool     // the pending dock step was finished
+		wantErr      bool
+	}{
+		{name: "no journal", disable: true},
+		{name: "before layout", done: []string{}, disable: true},
+		{name: "after layout", done: []string{stepLayout, stepRestart}, wantDock: true},
+		{name: "still running", done: []string{}, locked: true, wantErr: true},
+		{name: "config gone", steps: []string{stepLayout, stepRestart, stepDesktop, stepDock}, done: []string{stepLayout}, restart: true, removeConfig: true, wantRestart: true},
+		// default fills in the default config path although it never reads it
+		{name: "default", cmd: "default", steps: []string{stepLayout, stepRestart}, done: []string{stepLayout}, restart: true, removeConfig: true, wantRestart: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := journalFixture(t)
+			triggersDisabled(t, c, tt.disable)
+			if tt.cmd != "" {
+				c.Cmd = tt.cmd
+			}
+			c.NoRestart = !tt.restart
+			c.DockTimeout = time.Second
+			fake := fakeDock(t, runnertest.Result{}, 100, 200)
+
+			if tt.done != nil {
+				steps := tt.steps
+				if steps == nil {
+					steps = []string{stepLayout, stepRestart, stepFixOther, stepDesktop, stepDock}
+				}
+				j, err := c.beginJournal(steps...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if gotConfig := len(j.Config) > 0; gotConfig != needsConfig(steps) {
+					t.Errorf("beginJournal() config = '%s' for steps %v", j.Config, steps)
+				}
+				for _, step := range tt.done {
+					if err := j.done(step); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if tt.locked {
+					holdLock(t, os.Getppid(), j.Command)
+				}
+			}
+
+			if tt.removeConfig {
+				if err := os.Remove(c.File); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// the next invocation uses the live database, the journal knows better
+			err := Recover(&Config{Locator: c.Locator, Cmd: "load", NoRestart: true, DockTimeout: c.DockTimeout})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Recover() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !errors.Is(err, ErrLocked) {
+					t.Errorf("Recover() error = %v, want ErrLocked", err)
+				}
+				if j, err := readJournal(); err != nil || j == nil {
+					t.Errorf("Recover() removed the journal of a running run")
+				}
+				return
+			}
+
+			if triggersDisabled(t, c, false) {
+				t.Errorf("Recover() left the update triggers disabled")
+			}
+			if j, err := readJournal(); err != nil || j != nil {
+				t.Errorf("readJournal() = %v, %v, want it removed", j, err)
+			}
+			plist, err := dock.LoadDockPlist(c.DockPlist)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotRestart := slices.Contains(fake.Commands(), "killall Dock"); gotRestart != tt.wantRestart {
+				t.Errorf("Recover() restarted the Dock %v, want %v", gotRestart, tt.wantRestart)
+			}
+			if gotDock := len(plist.PersistentApps) == 1; gotDock != tt.wantDock {
+				t.Errorf("Recover() dock apps = %d, want the dock step finished %v", len(plist.PersistentApps), tt.wantDock)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/blacktop/lporg/internal/database"
+	"github.com/blacktop/lporg/internal/runner"
+	"github.com/blacktop/lporg/internal/utils"
+)
+
+const dockPlistPath = "Library/Preferences/com.apple.dock.plist"
+
+// Locator finds the Launchpad database and Dock plist lporg operates on.
+// Empty fields fall back to the files used by the running Dock.
+type Locator struct {
+	DB        string
+	DockPlist string
+}
+
+// Launchpad database locations
+const (
+	// launchpadDBPath is relative to $TMPDIR on macOS 10.13 High Sierra and later
+	launchpadDBPath = "../0/com.apple.dock.launchpad/db/db"
+	// legacyDBDir is relative to $HOME before High Sierra and holds <hardware UUID>.db
+	legacyDBDir = "Library/Application Support/Dock"
+)
+
+// Where a Launchpad database was found
+const (
+	SourceFlag   = "--db"
+	SourceTmpDir = "tmpdir"
+	SourceLegacy = "legacy"
+)
+
+var (
+	legacyDBRE     = regexp.MustCompile(`(?i)^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\.db$`)
+	platformUUIDRE = regexp.MustCompile(`"IOPlatformUUID" = "([^"]+)"`)
+)
+
+// hardwareUUID returns this Mac's hardware UUID, which legacy databases are named after
+var hardwareUUID = func(ctx context.Context) (string, error) {
+	out, err := runner.Run(ctx, runner.Command{Name: "ioreg", Args: []string{"-rd1", "-c", "IOPlatformExpertDevice"}, Timeout: 10 * time.Second})
+	if err != nil {
+		return "", fmt.Errorf("failed to read hardware UUID: %w", err)
+	}
+	if m := platformUUIDRE.FindStringSubmatch(out); m != nil {
+		return m[1], nil
+	}
+	return "", fmt.Errorf("hardware UUID not found in ioreg output")
+}
+
+// DBLocation is a Launchpad database and where it was found
+type DBLocation struct {
+	Path   string
+	Source string
+}
+
+// LocateLaunchpadDB returns the Launchpad database given with --db, or probes the
+// High Sierra and later location and then the legacy location
+func (l Locator) LocateLaunchpadDB(ctx context.Context) (DBLocation, error) {
+	if len(l.DB) > 0 {
+		if _, err := os.Stat(l.DB); err != nil {
+			return DBLocation{}, fmt.Errorf("%w: %v", database.ErrDBNotFound, err)
+		}
+		path, err := filepath.Abs(l.DB)
+		if err != nil {
+			return DBLocation{}, err
+		}
+		return DBLocation{Path: path, Source: SourceFlag}, nil
+	}
+
+	var tried []string
+
+	// High Sierra //////////////////////////////
+	// $TMPDIR../0/com.apple.dock.launchpad/db/db
+	if tmpDir := os.Getenv("TMPDIR"); len(tmpDir) > 0 {
+		path := filepath.Join(tmpDir, launchpadDBPath)
+		if _, err := os.Stat(path); err == nil {
+			return DBLocation{Path: path, Source: SourceTmpDir}, nil
+		}
+		tried = append(tried, path)
+	}
+
+	// Older macOS ////////////////////////////////
+	// $HOME/Library/Application\ Support/Dock/*.db
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return DBLocation{}, fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	dir := filepath.Join(home, legacyDBDir)
+	path, err := legacyDB(ctx, dir)
+	if err == nil {
+		return DBLocation{Path: path, Source: SourceLegacy}, nil
+	}
+	tried = append(tried, filepath.Join(dir, "<UUID>.db"))
+
+	return DBLocation{}, fmt.Errorf("%w (tried %s): %v", database.ErrDBNotFound, strings.Join(tried, ", "), err)
+}
+
+// legacyDB returns the UUID named database in dir. If there are several it picks the
+// one named after this Mac's hardware UUID, falling back to the most recently modified.
+func legacyDB(ctx context.Context, dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	var (
+		candidates []string
+		newest     string
+		newestTime time.Time
+	)
+	for _, entry := range entries {
+		if entry.IsDir() || !legacyDBRE.MatchString(entry.Name()) {
+			continue // e.g. desktoppicture.db
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		candidates = append(candidates, path)
+		if info.ModTime().After(newestTime) {
+			newest, newestTime = path, info.ModTime()
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return "", fmt.Errorf("no <UUID>.db file in %s", dir)
+	case 1:
+		return candidates[0], nil
+	}
+
+	if id, err := hardwareUUID(ctx); err == nil {
+		for _, path := range candidates {
+			if strings.EqualFold(strings.TrimSuffix(filepath.Base(path), ".db"), id) {
+				return path, nil
+			}
+		}
+	} else {
+		utils.Indent(log.WithError(err).Warn, 2)("unable to match legacy launchpad database to this Mac")
+	}
+	utils.Indent(log.WithFields(log.Fields{"count": len(candidates), "database": newest}).Warn, 2)("found several legacy launchpad databases, using the most recently modified")
+	return newest, nil
+}
+
+// DockPlistPath returns the path to the Dock preferences plist
+func (l Locator) DockPlistPath() (string, error) {
+	if len(l.DockPlist) > 0 {
+		return filepath.Abs(l.DockPlist)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(home, dockPlistPath), nil
+}
+
+// LiveDB returns true if the Launchpad database is the one owned by the running Dock
+func (l Locator) LiveDB() bool {
+	return len(l.DB) == 0
+}
+
+// LiveDockPlist returns true if the Dock plist is the one owned by the running Dock
+func (l Locator) LiveDockPlist() bool {
+	return len(l.DockPlist) == 0
+}
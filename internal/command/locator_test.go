@@ -0,0 +1,77 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLocator_LocateLaunchpadDB(t *testing.T) {
+	const (
+		uuidA = "0A1B2C3D-0000-4000-8000-00000000000A"
+		uuidB = "0A1B2C3D-0000-4000-8000-00000000000B"
+	)
+	tests := []struct {
+		name       string
+		tmpdir     bool     // create the High Sierra database
+		legacy     []string // legacy database file names, oldest first
+		hwUUID     string
+		wantSource string
+		wantFile   string
+		wantErr    bool
+	}{
+		{name: "tmpdir", tmpdir: true, legacy: []string{uuidA + ".db"}, wantSource: SourceTmpDir, wantFile: "db"},
+		{name: "legacy", legacy: []string{"desktoppicture.db", uuidA + ".db"}, wantSource: SourceLegacy, wantFile: uuidA + ".db"},
+		{name: "legacy hardware UUID", legacy: []string{uuidA + ".db", uuidB + ".db"}, hwUUID: uuidA, wantSource: SourceLegacy, wantFile: uuidA + ".db"},
+		{name: "legacy newest", legacy: []string{uuidA + ".db", uuidB + ".db"}, wantSource: SourceLegacy, wantFile: uuidB + ".db"},
+		{name: "none", legacy: []string{"desktoppicture.db"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home, tmp := t.TempDir(), t.TempDir()
+			t.Setenv("HOME", home)
+			t.Setenv("TMPDIR", filepath.Join(tmp, "T"))
+
+			touch := func(path string, mtime time.Time) {
+				if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chtimes(path, mtime, mtime); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.tmpdir {
+				touch(filepath.Join(tmp, "0", "com.apple.dock.launchpad", "db", "db"), time.Now())
+			}
+			for idx, name := range tt.legacy {
+				touch(filepath.Join(home, legacyDBDir, name), time.Now().Add(time.Duration(idx-len(tt.legacy))*time.Hour))
+			}
+
+			orig := hardwareUUID
+			t.Cleanup(func() { hardwareUUID = orig })
+			hardwareUUID = func(context.Context) (string, error) {
+				if len(tt.hwUUID) == 0 {
+					return "", fmt.Errorf("no ioreg")
+				}
+				return tt.hwUUID, nil
+			}
+
+			loc, err := Locator{}.LocateLaunchpadDB(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LocateLaunchpadDB() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if loc.Source != tt.wantSource || filepath.Base(loc.Path) != tt.wantFile {
+				t.Errorf("LocateLaunchpadDB() = %+v, want %s %s", loc, tt.wantSource, tt.wantFile)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package command
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/blacktop/lporg/internal/utils"
+)
+
+const lockFile = "lporg.lock"
+
+// lockPollInterval is how often a run waiting for the lock retries
+var lockPollInterval = 100 * time.Millisecond
+
+// the lock held by this process. It is reentrant so commands can call each other.
+var (
+	lockMu    sync.Mutex
+	lockHeld  *os.File
+	lockCount int
+)
+
+// lockHolder is written to the lock file so a blocked run can say who holds it
+type lockHolder struct {
+	PID     int       `json:"pid"`
+	Command string    `json:"command"`
+	Started time.Time `json:"started"`
+}
+
+func (h lockHolder) String() string {
+	return fmt.Sprintf("lporg %s (pid %d) started %s", h.Command, h.PID, h.Started.Format(time.RFC3339))
+}
+
+// lockPath returns the lock file path. It is always local, even with --icloud.
+func lockPath() (string, error) {
+	dir, err := localDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, lockFile), nil
+}
+
+// lock takes the advisory lock that keeps lporg runs that change the Launchpad
+// database, Dock or backups from overlapping. If another run holds it, it waits
+// up to --lock-wait and then fails with ErrLocked. The returned func releases it.
+func (c *Config) lock() (func(), error) {
+	lockMu.Lock()
+	defer lockMu.Unlock()
+
+	if lockHeld != nil {
+		lockCount++
+		return c.unlock, nil
+	}
+
+	path, err := lockPath()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, fmt.Errorf("failed to create lock dir: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	deadline := time.Now().Add(c.LockWait)
+	waiting := false
+	for {
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		holder := readLockHolder(path)
+		if time.Now().After(deadline) {
+			f.Close()
+			if c.LockWait > 0 {
+				return nil, fmt.Errorf("%w: still held by %s after waiting %s", ErrLocked, holder, c.LockWait)
+			}
+			return nil, fmt.Errorf("%w: held by %s (use --lock-wait to wait for it)", ErrLocked, holder)
+		}
+		if !waiting {
+			utils.Indent(log.WithField("holder", holder).Warn, 2)("waiting for another lporg run to finish")
+			waiting = true
+		}
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, ErrInterrupted
+		case <-time.After(lockPollInterval):
+		}
+	}
+
+	data, err := json.Marshal(lockHolder{PID: os.Getpid(), Command: c.Cmd, Started: time.Now()})
+	if err == nil {
+		if err = f.Truncate(0); err == nil {
+			_, err = f.WriteAt(data, 0)
+		}
+	}
+	if err != nil {
+		utils.Indent(log.WithError(err).Debug, 2)("failed to record lock holder")
+	}
+
+	lockHeld = f
+	lockCount = 1
+	return c.unlock, nil
+}
+
+// unlock releases the lock once every lock call of this process released it
+func (c *Config) unlock() {
+	lockMu.Lock()
+	defer lockMu.Unlock()
+
+	if lockCount--; lockCount > 0 || lockHeld == nil {
+		return
+	}
+	lockHeld.Truncate(0)
+	syscall.Flock(int(lockHeld.Fd()), syscall.LOCK_UN)
+	lockHeld.Close()
+	lockHeld = nil
+}
+
+// readLockHolder returns who holds the lock according to the lock file
+func readLockHolder(path string) fmt.Stringer {
+	var holder lockHolder
+	data, err := os.ReadFile(path)
+	if err != nil || json.Unmarshal(data, &holder) != nil {
+		return unknownHolder{}
+	}
+	return holder
+}
+
+type unknownHolder struct{}
+
+func (unknownHolder) String() string { return "another lporg run" }
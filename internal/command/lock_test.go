@@ -0,0 +1,112 @@
+package command
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// holdLock takes the lock the way another lporg process would and returns a func releasing it
+func holdLock(t *testing.T, pid int, cmd string) func() {
+	t.Helper()
+	path, err := lockPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(lockHolder{PID: pid, Command: cmd, Started: time.Now()})
+	if _, err := f.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	release := func() { f.Close() }
+	t.Cleanup(release)
+	return release
+}
+
+// lockFree returns whether another process could take the lock
+func lockFree(t *testing.T) bool {
+	t.Helper()
+	path, err := lockPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB) == nil
+}
+
+func TestConfig_lock(t *testing.T) {
+	log.SetLevel(log.ErrorLevel)
+	orig := lockPollInterval
+	t.Cleanup(func() { lockPollInterval = orig })
+	lockPollInterval = 10 * time.Millisecond
+
+	tests := []struct {
+		name    string
+		held    bool          // another run holds the lock
+		release time.Duration // when the other run releases it, 0 for never
+		wait    time.Duration
+		wantErr string
+	}{
+		{name: "free"},
+		{name: "fail fast", held: true, wantErr: "held by lporg save (pid 4242)"},
+		{name: "wait", held: true, release: 50 * time.Millisecond, wait: 5 * time.Second},
+		{name: "wait too long", held: true, wait: 50 * time.Millisecond, wantErr: "still held by lporg save (pid 4242)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setConfigDir(t)
+			if tt.held {
+				release := holdLock(t, 4242, "save")
+				if tt.release > 0 {
+					time.AfterFunc(tt.release, release)
+				}
+			}
+
+			c := &Config{Cmd: "load", LockWait: tt.wait}
+			unlock, err := c.lock()
+			if len(tt.wantErr) > 0 {
+				if !errors.Is(err, ErrLocked) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("lock() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("lock() error = %v", err)
+			}
+
+			// commands calling each other take it again
+			unlockAgain, err := c.lock()
+			if err != nil {
+				t.Fatalf("lock() again error = %v", err)
+			}
+			unlockAgain()
+			if lockFree(t) {
+				t.Errorf("lock() released by the inner unlock")
+			}
+			unlock()
+			if !lockFree(t) {
+				t.Errorf("lock() not released")
+			}
+		})
+	}
+}
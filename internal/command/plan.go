@@ -0,0 +1,292 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/blacktop/lporg/internal/database"
+	"github.com/blacktop/lporg/internal/dock"
+	"golang.org/x/exp/slices"
+)
+
+// Location is where an app sits in the launchpad layout
+type Location struct {
+	Page       int    `json:"page"`
+	Folder     string `json:"folder,omitempty"`
+	FolderPage int    `json:"folder_page,omitempty"`
+	Position   int    `json:"position,omitempty"`
+}
+
+func (l Location) String() string {
+	var out string
+	if len(l.Folder) > 0 {
+		out = fmt.Sprintf("page %d, folder '%s'", l.Page, l.Folder)
+		if l.FolderPage > 0 {
+			out += fmt.Sprintf(" page %d", l.FolderPage)
+		}
+	} else {
+		out = fmt.Sprintf("page %d", l.Page)
+	}
+	if l.Position > 0 {
+		return out + fmt.Sprintf(", position %d", l.Position)
+	}
+	return out + ", at the end"
+}
+
+// PlannedPage is a launchpad page that will be created
+type PlannedPage struct {
+	Number int `json:"number"`
+	Items  int `json:"items"`
+}
+
+// PlannedFolder is a launchpad folder that will be created
+type PlannedFolder struct {
+	Name  string `json:"name"`
+	Page  int    `json:"page"`
+	Pages int    `json:"pages"`
+}
+
+// PlannedMove is an app that will change location
+type PlannedMove struct {
+	App  string    `json:"app"`
+	From *Location `json:"from,omitempty"`
+	To   *Location `json:"to,omitempty"`
+}
+
+// DockPlan lists the Dock tiles that will be added and removed
+type DockPlan struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// Plan describes every change a load or default would make
+type Plan struct {
+	Pages     []PlannedPage   `json:"pages,omitempty"`
+	Folders   []PlannedFolder `json:"folders,omitempty"`
+	Moves     []PlannedMove   `json:"moves,omitempty"`
+	Missing   []string        `json:"missing,omitempty"`
+	Appended  []string        `json:"appended,omitempty"`
+	Ignored   []string        `json:"ignored,omitempty"`
+	Empty     []string        `json:"empty_folders,omitempty"`
+	Relocated []PlannedMove   `json:"relocated,omitempty"`
+	Dock      *DockPlan       `json:"dock,omitempty"`
+
+	Incremental *database.ReconcileStats `json:"incremental,omitempty"`
+
+	Report *database.MissingReport `json:"-"`
+}
+
+// Print writes the plan to w as human readable text or as JSON
+func (p *Plan) Print(w io.Writer, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(p)
+	}
+
+	fmt.Fprintln(w, "Launchpad:")
+	if p.Incremental != nil {
+		fmt.Fprintf(w, "  = %s\n", p.Incremental)
+	}
+	for _, page := range p.Pages {
+		fmt.Fprintf(w, "  + create page %d (%d items)\n", page.Number, page.Items)
+	}
+	for _, folder := range p.Folders {
+		fmt.Fprintf(w, "  + create folder '%s' on page %d (%d pages)\n", folder.Name, folder.Page, folder.Pages)
+	}
+	for _, move := range p.Moves {
+		from := "nowhere"
+		if move.From != nil {
+			from = move.From.String()
+		}
+		fmt.Fprintf(w, "  ~ move %s: %s -> %s\n", move.App, from, move.To)
+	}
+	for _, app := range p.Appended {
+		fmt.Fprintf(w, "  + place %s (installed but not in config)\n", app)
+	}
+	for _, app := range p.Ignored {
+		fmt.Fprintf(w, "  = leave %s where Launchpad puts it (installed but not in config)\n", app)
+	}
+	for _, app := range p.Missing {
+		fmt.Fprintf(w, "  - skip %s (in config but not installed)\n", app)
+	}
+	for _, folder := range p.Empty {
+		fmt.Fprintf(w, "  - drop folder '%s' (no installed apps)\n", folder)
+	}
+	for _, move := range p.Relocated {
+		fmt.Fprintf(w, "  ~ relocate %s from Other -> %s\n", move.App, move.To)
+	}
+	if p.Dock != nil {
+		fmt.Fprintln(w, "Dock:")
+		if len(p.Dock.Added) == 0 && len(p.Dock.Removed) == 0 {
+			fmt.Fprintln(w, "  no changes")
+		}
+		for _, tile := range p.Dock.Added {
+			fmt.Fprintf(w, "  + add %s\n", tile)
+		}
+		for _, tile := range p.Dock.Removed {
+			fmt.Fprintf(w, "  - remove %s\n", tile)
+		}
+	}
+	return nil
+}
+
+type placedApp struct {
+	App      database.App
+	Location Location
+}
+
+// snapshot returns every app reachable from the launchpad root in layout order
+func snapshot(lpad *database.LaunchPad) ([]placedApp, error) {
+	layout, err := lpad.ReadLayout()
+	if err != nil {
+		return nil, err
+	}
+
+	var placed []placedApp
+	for pageIdx, page := range layout.Launchpad {
+		for itemIdx, item := range page.Items {
+			switch item.Type {
+			case database.ApplicationType:
+				placed = append(placed, placedApp{
+					App:      item.App,
+					Location: Location{Page: pageIdx + 1, Position: itemIdx + 1},
+				})
+			case database.FolderRootType:
+				for fpIdx, fpage := range item.Pages {
+					for appIdx, app := range fpage.Apps {
+						placed = append(placed, placedApp{
+							App: app.App,
+							Location: Location{
+								Page:       pageIdx + 1,
+								Folder:     item.Group.Title,
+								FolderPage: fpIdx + 1,
+								Position:   appIdx + 1,
+							},
+						})
+					}
+				}
+			}
+		}
+	}
+
+	return placed, nil
+}
+
+// plan runs rebuild, or reconcile if incremental is set, on lpad and reports what it
+// changed. lpad must be a snapshot, the changes are left in it.
+func plan(lpad *database.LaunchPad, dPlist *dock.Plist, incremental bool) (*Plan, error) {
+	var (
+		p      Plan
+		report *database.MissingReport
+	)
+
+	before, err := snapshot(lpad)
+	if err != nil {
+		return nil, err
+	}
+
+	if incremental {
+		p.Incremental, report, err = reconcile(lpad)
+	} else {
+		report, err = rebuild(lpad)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := snapshot(lpad)
+	if err != nil {
+		return nil, err
+	}
+
+	prev := make(map[int]Location, len(before))
+	for _, app := range before {
+		prev[app.App.ID] = app.Location
+	}
+	placed := make(map[int]bool, len(after))
+	for _, app := range after {
+		placed[app.App.ID] = true
+		from, ok := prev[app.App.ID]
+		if ok && from == app.Location {
+			continue
+		}
+		move := PlannedMove{App: app.App.Title, To: &app.Location}
+		if ok {
+			move.From = &from
+		}
+		p.Moves = append(p.Moves, move)
+	}
+
+	// FixOther moves apps the Dock finds without a parent to the config folder
+	// they belong in or to the end of the first page
+	ignore := lpad.Config.Missing.Policy == database.PlaceIgnore
+	for _, app := range before {
+		if placed[app.App.ID] || (ignore && slices.Contains(report.Unlisted, app.App.Title)) {
+			continue
+		}
+		to := Location{Page: 1}
+		if folder, err := lpad.Config.GetFolderContainingApp(app.App); err == nil {
+			to.Folder = folder
+		}
+		from := app.Location
+		p.Relocated = append(p.Relocated, PlannedMove{App: app.App.Title, From: &from, To: &to})
+	}
+
+	p.Report = report
+	p.Appended, p.Missing, p.Empty = report.Unlisted, report.Uninstalled, report.EmptyFolders
+	if lpad.Config.Missing.Policy == database.PlaceIgnore {
+		p.Appended, p.Ignored = nil, p.Appended
+	}
+
+	if !incremental { // an incremental load reuses the pages and folders it can
+		for _, page := range lpad.Config.Apps.Pages {
+			p.Pages = append(p.Pages, PlannedPage{Number: page.Number, Items: len(page.Items)})
+			for _, item := range page.Items {
+				folder := item.Folder
+				if folder == nil {
+					continue
+				}
+				p.Folders = append(p.Folders, PlannedFolder{Name: folder.Name, Page: page.Number, Pages: len(folder.Pages)})
+			}
+		}
+	}
+
+	if dPlist != nil {
+		p.Dock = planDock(lpad.Config.Dock, dPlist)
+	}
+
+	return &p, nil
+}
+
+// planDock compares the Dock tiles in the config with the ones in the Dock plist
+func planDock(conf database.Dock, dPlist *dock.Plist) *DockPlan {
+	var current, wanted []string
+
+	home, _ := os.UserHomeDir()
+	for _, item := range dPlist.PersistentApps {
+		current = append(current, item.TileData.GetPath())
+	}
+	for _, item := range dPlist.PersistentOthers {
+		current = append(current, homeRelPath(home, item.TileData.GetPath()))
+	}
+	wanted = append(wanted, conf.Apps...)
+	for _, other := range conf.Others {
+		wanted = append(wanted, other.Path)
+	}
+
+	var dp DockPlan
+	for _, tile := range wanted {
+		if !slices.Contains(current, tile) {
+			dp.Added = append(dp.Added, tile)
+		}
+	}
+	for _, tile := range current {
+		if !slices.Contains(wanted, tile) {
+			dp.Removed = append(dp.Removed, tile)
+		}
+	}
+	return &dp
+}
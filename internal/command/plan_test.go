@@ -0,0 +1,45 @@
+package command
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestPlanLoad(t *testing.T) {
+	tests := []struct {
+		name  string
+		alter string // SQL run on the live database first
+	}{
+		{name: "known schema"},
+		{name: "unknown schema", alter: "DROP TRIGGER insert_item;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := journalFixture(t)
+			if len(tt.alter) > 0 {
+				execSQL(t, c.DB, tt.alter)
+			}
+			live, err := os.ReadFile(c.DB)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			plan, err := PlanLoad(c)
+			if err != nil {
+				t.Fatalf("PlanLoad() error = %v", err)
+			}
+			if len(plan.Moves) != 3 || len(plan.Folders) != 1 || plan.Folders[0].Name != "Apps" {
+				t.Errorf("PlanLoad() moves = %v, folders = %v, want 3 apps moved into 'Apps'", plan.Moves, plan.Folders)
+			}
+
+			after, err := os.ReadFile(c.DB)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(after, live) {
+				t.Errorf("PlanLoad() changed the live database")
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package command
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/blacktop/lporg/internal/database"
+	"github.com/blacktop/lporg/internal/database/testdb"
+	"gopkg.in/yaml.v3"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// roundTrip saves the layout of a fixture database, loads the saved config into a
+// fresh database holding the same apps and saves that again. It returns both configs.
+func roundTrip(t *testing.T, layout testdb.Layout, bundleIDs bool) (first, second []byte) {
+	t.Helper()
+	log.SetLevel(log.ErrorLevel)
+
+	dir := t.TempDir()
+	t.Setenv("HOME", dir) // keeps Dock folder paths in the saved configs the same everywhere
+	plist, err := os.ReadFile(filepath.Join("..", "..", ".hack", "test", "com.apple.dock.plist"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dockPlist := filepath.Join(dir, "com.apple.dock.plist")
+	if err := os.WriteFile(dockPlist, plist, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	save := func(db, file string) []byte {
+		c := &Config{
+			Locator:   Locator{DB: db, DockPlist: dockPlist},
+			Cmd:       "save",
+			File:      file,
+			BundleIDs: bundleIDs,
+		}
+		if err := SaveConfig(c); err != nil {
+			t.Fatalf("SaveConfig() error = %v", err)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	first = save(testdb.New(t, layout), filepath.Join(dir, "first.yml"))
+
+	fresh := filepath.Join(dir, "fresh", "db")
+	if err := testdb.Create(fresh, testdb.Flat(layout.Apps(), 35)); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadConfig(&Config{
+		Locator:   Locator{DB: fresh, DockPlist: dockPlist},
+		Cmd:       "load",
+		File:      filepath.Join(dir, "first.yml"),
+		NoRestart: true,
+	}); err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	return first, save(fresh, filepath.Join(dir, "second.yml"))
+}
+
+func TestRoundTrip_Golden(t *testing.T) {
+	tests := []struct {
+		name      string
+		bundleIDs bool
+	}{
+		{name: "basic"},
+		{name: "bundle-ids", bundleIDs: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", "roundtrip", tt.name+".yml"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			layout, err := testdb.Parse(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			first, second := roundTrip(t, layout, tt.bundleIDs)
+
+			golden := filepath.Join("testdata", "roundtrip", tt.name+".golden.yml")
+			if *update {
+				if err := os.WriteFile(golden, first, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run go test -update to create it)", err)
+			}
+			if !bytes.Equal(first, want) {
+				t.Errorf("save = \n%s\nwant (%s)\n%s", first, golden, want)
+			}
+			if !bytes.Equal(second, first) {
+				t.Errorf("save after load = \n%s\nwant\n%s", second, first)
+			}
+		})
+	}
+}
+
+// randomLayout returns a layout of up to 4 pages of apps and folders, some with several pages
+func randomLayout(rng *rand.Rand) testdb.Layout {
+	var (
+		layout  testdb.Layout
+		apps    int
+		folders int
+	)
+	newApp := func() testdb.App {
+		apps++
+		return testdb.App{Title: testdb.Title(apps), Bundle: testdb.Bundle(apps)}
+	}
+	for p := rng.Intn(4) + 1; p > 0; p-- {
+		var page []testdb.Item
+		for i := rng.Intn(35) + 1; i > 0; i-- {
+			if rng.Intn(4) > 0 {
+				app := newApp()
+				page = append(page, testdb.Item{App: &app})
+				continue
+			}
+			folders++
+			folder := testdb.Item{Folder: fmt.Sprintf("Folder %d", folders)}
+			for fp := rng.Intn(3) + 1; fp > 0; fp-- {
+				var fpage []testdb.App
+				for a := rng.Intn(10) + 1; a > 0; a-- {
+					fpage = append(fpage, newApp())
+				}
+				folder.Pages = append(folder.Pages, fpage)
+			}
+			page = append(page, folder)
+		}
+		layout.Pages = append(layout.Pages, page)
+	}
+	return layout
+}
+
+// configApps returns the config a save of the layout should produce
+func configApps(layout testdb.Layout) database.Apps {
+	var apps database.Apps
+	for pidx, page := range layout.Pages {
+		p := database.Page{Number: pidx + 1}
+		for _, item := range page {
+			if item.App != nil {
+				p.Items = append(p.Items, database.AppItem(database.AppRef{Title: item.App.Title, Bundle: item.App.Bundle}))
+				continue
+			}
+			folder := database.AppFolder{Name: item.Folder}
+			for fidx, fpage := range item.Pages {
+				fp := database.FolderPage{Number: fidx + 1}
+				for _, app := range fpage {
+					fp.Items = append(fp.Items, database.AppRef{Title: app.Title, Bundle: app.Bundle})
+				}
+				folder.Pages = append(folder.Pages, fp)
+			}
+			p.Items = append(p.Items, database.FolderItem(folder))
+		}
+		apps.Pages = append(apps.Pages, p)
+	}
+	return apps
+}
+
+func TestRoundTrip_Random(t *testing.T) {
+	seeds := 20
+	if testing.Short() {
+		seeds = 3
+	}
+	for seed := 1; seed <= seeds; seed++ {
+		t.Run(fmt.Sprint("seed-", seed), func(t *testing.T) {
+			layout := randomLayout(rand.New(rand.NewSource(int64(seed))))
+
+			first, second := roundTrip(t, layout, true)
+			if !bytes.Equal(second, first) {
+				t.Fatalf("save after load differs from the first save:\n%s", diff(first, second))
+			}
+
+			var conf database.Config
+			if err := yaml.Unmarshal(first, &conf); err != nil {
+				t.Fatal(err)
+			}
+			if want := configApps(layout); !reflect.DeepEqual(conf.Apps, want) {
+				t.Errorf("save = %v, want %v", conf.Apps.Pages, want.Pages)
+			}
+		})
+	}
+}
+
+// diff returns the first line where a and b differ
+func diff(a, b []byte) string {
+	al, bl := strings.Split(string(a), "\n"), strings.Split(string(b), "\n")
+	for idx := 0; idx < len(al) && idx < len(bl); idx++ {
+		if al[idx] != bl[idx] {
+			return fmt.Sprintf("line %d: %q != %q", idx+1, al[idx], bl[idx])
+		}
+	}
+	return fmt.Sprintf("%d lines != %d lines", len(al), len(bl))
+}
@@ -5,9 +5,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/apex/log"
+	"github.com/blacktop/lporg/internal/database"
+	"github.com/blacktop/lporg/internal/dock"
+	"github.com/blacktop/lporg/internal/runner"
 	"github.com/blacktop/lporg/internal/utils"
 	"github.com/pkg/errors"
 )
@@ -69,22 +74,87 @@ var PorgASCIIArt = `
 
 `
 
-func restartDock() error {
+// dockPollInterval is how often restartDock checks whether the Dock is back
+var dockPollInterval = 250 * time.Millisecond
+
+// dockPID returns the PID of this user's Dock or 0 if it is not running
+func dockPID(ctx context.Context) (int, error) {
+	out, err := runner.Run(ctx, runner.Command{Name: "pgrep", Args: []string{"-x", "-u", strconv.Itoa(os.Getuid()), "Dock"}, Timeout: 10 * time.Second})
+	if err != nil {
+		if runner.ExitCode(err) == 1 { // no match
+			return 0, nil
+		}
+		return 0, err
+	}
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return 0, nil
+	}
+	return strconv.Atoi(fields[0])
+}
+
+// restartDock kills the Dock and waits until launchd has started a new one
+func (c *Config) restartDock(ctx context.Context) error {
 	utils.Indent(log.Info, 2)("restarting Dock")
-	if _, err := utils.RunCommand(context.Background(), "killall", "Dock"); err != nil {
-		return errors.Wrap(err, "killing Dock process failed")
+	oldPID, err := dockPID(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrDockRestart, err)
+	}
+	if _, err := runner.Run(ctx, runner.Command{Name: "killall", Args: []string{"Dock"}, Timeout: 10 * time.Second}); err != nil {
+		return fmt.Errorf("%w: killing Dock process failed: %w", ErrDockRestart, err)
+	}
+
+	return c.waitForDock(ctx, oldPID)
+}
+
+// startDock starts the Dock stopped with dock.Stop and waits for it to come back and
+// open the launchpad database
+func (c *Config) startDock(ctx context.Context, oldPID int, file string) error {
+	utils.Indent(log.Info, 2)("starting Dock")
+	if err := dock.Start(ctx); err != nil {
+		return fmt.Errorf("%w: %w", ErrDockRestart, err)
+	}
+	if err := c.waitForDock(ctx, oldPID); err != nil {
+		return err
+	}
+	if err := database.WaitReady(ctx, file, c.DockTimeout); err != nil {
+		return fmt.Errorf("%w: %w (raise --dock-timeout if the Dock is just slow)", ErrDockRestart, err)
 	}
-	// let system settle
-	time.Sleep(2 * time.Second)
 	return nil
 }
 
-func removeOldDatabaseFiles(dbpath string) error {
+// waitForDock waits until a Dock other than oldPID is running
+func (c *Config) waitForDock(ctx context.Context, oldPID int) error {
+	utils.Indent(log.WithFields(log.Fields{"pid": oldPID, "timeout": c.DockTimeout}).Debug, 2)("waiting for a new Dock")
+	deadline := time.Now().Add(c.DockTimeout)
+	for {
+		pid, err := dockPID(ctx)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrDockRestart, err)
+		}
+		if pid != 0 && pid != oldPID {
+			utils.Indent(log.WithField("pid", pid).Debug, 2)("Dock restarted")
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%w: the Dock did not come back within %s (raise --dock-timeout if it is just slow)", ErrDockRestart, c.DockTimeout)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(dockPollInterval):
+		}
+	}
+}
+
+// removeDatabaseFiles removes the database file and its WAL and shared memory files,
+// restarts the Dock and waits for it to rebuild the database
+func (c *Config) removeDatabaseFiles(ctx context.Context, file string) error {
 
 	paths := []string{
-		filepath.Join(dbpath, "db"),
-		filepath.Join(dbpath, "db-shm"),
-		filepath.Join(dbpath, "db-wal"),
+		file,
+		file + "-shm",
+		file + "-wal",
 	}
 
 	for _, path := range paths {
@@ -98,7 +168,23 @@ func removeOldDatabaseFiles(dbpath string) error {
 		utils.Indent(log.WithField("path", path).Info, 3)("removed old DB file")
 	}
 
-	return restartDock()
+	if err := c.restartDock(ctx); err != nil {
+		return err
+	}
+	utils.Indent(log.WithField("timeout", c.DockTimeout).Debug, 2)("waiting for the Dock to rebuild the launchpad database")
+	if err := database.WaitReady(ctx, file, c.DockTimeout); err != nil {
+		return fmt.Errorf("%w: %w (raise --dock-timeout if the Dock is just slow)", ErrDockRestart, err)
+	}
+	return nil
+}
+
+// hostName returns the host name iCloud config files are named after
+func hostName() (string, error) {
+	host, err := os.Hostname()
+	if err != nil {
+		return "", fmt.Errorf("failed to get hostname")
+	}
+	return strings.TrimRight(host, ".local"), nil
 }
 
 func getiCloudDrivePath() (string, error) {
@@ -109,6 +195,18 @@ func getiCloudDrivePath() (string, error) {
 	return filepath.Join(home, "Library/Mobile Documents/com~apple~CloudDocs"), nil
 }
 
+// homeRelPath returns path as ~/... if it is inside home and unchanged otherwise
+func homeRelPath(home, path string) string {
+	if len(home) == 0 {
+		return path
+	}
+	relPath, err := filepath.Rel(home, path)
+	if err != nil || relPath == ".." || strings.HasPrefix(relPath, "../") {
+		return path
+	}
+	return filepath.Join("~", relPath)
+}
+
 func split[T any](buf []T, lim int) [][]T {
 	var chunk []T
 	chunks := make([][]T, 0, lim)
@@ -0,0 +1,203 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/blacktop/lporg/internal/database"
+	"github.com/blacktop/lporg/internal/database/testdb"
+	"github.com/blacktop/lporg/internal/runner/runnertest"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// liveDB creates a database where the locator looks for the running Dock's and returns its path
+func liveDB(t *testing.T, layout testdb.Layout) string {
+	t.Helper()
+	tmp := filepath.Join(t.TempDir(), "T")
+	if err := os.Mkdir(tmp, 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TMPDIR", tmp)
+	db := filepath.Join(tmp, launchpadDBPath)
+	if err := testdb.Create(db, layout); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// execSQL runs stmt on the database file, e.g. to make its schema unknown
+func execSQL(t *testing.T, file, stmt string) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(file), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+	if err := db.Exec(stmt).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// pgrep returns the results of successive pgrep calls finding the Dock PIDs, 0 for none
+func pgrep(pids ...int) []runnertest.Result {
+	results := make([]runnertest.Result, 0, len(pids))
+	for _, pid := range pids {
+		if pid == 0 {
+			results = append(results, runnertest.Result{ExitCode: 1})
+		} else {
+			results = append(results, runnertest.Result{Stdout: fmt.Sprintln(pid)})
+		}
+	}
+	return results
+}
+
+// fakeDock installs a fake runner where killall and pgrep behave like the Dock
+func fakeDock(t *testing.T, killall runnertest.Result, pids ...int) *runnertest.Fake {
+	t.Helper()
+	log.SetLevel(log.ErrorLevel)
+	orig := dockPollInterval
+	t.Cleanup(func() { dockPollInterval = orig })
+	dockPollInterval = 5 * time.Millisecond
+	return runnertest.Install(t).On("killall Dock", killall).On("pgrep", pgrep(pids...)...)
+}
+
+func TestRestartDock(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		killall runnertest.Result
+		pids    []int // Dock PIDs found before killall and while waiting
+		wantRun []string
+		wantErr []error
+	}{
+		{
+			// the layout was written, so only a new Dock shows the restart happened
+			name:    "restart after a write",
+			ctx:     context.Background(),
+			pids:    []int{100, 100, 0, 200},
+			wantRun: []string{"pgrep", "killall Dock", "pgrep", "pgrep", "pgrep"},
+		},
+		{
+			name:    "never comes back",
+			ctx:     context.Background(),
+			pids:    []int{100, 0},
+			wantErr: []error{ErrDockRestart},
+		},
+		{
+			name:    "no Dock running",
+			ctx:     context.Background(),
+			killall: runnertest.Result{Stderr: "No matching processes belonging to you were found\n"},
+			pids:    []int{0},
+			wantRun: []string{"pgrep", "killall Dock"},
+			wantErr: []error{ErrDockRestart},
+		},
+		{
+			name:    "interrupted",
+			ctx:     cancelled,
+			pids:    []int{100},
+			wantRun: []string{"pgrep"},
+			wantErr: []error{context.Canceled},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := fakeDock(t, tt.killall, tt.pids...)
+			c := &Config{DockTimeout: 100 * time.Millisecond}
+
+			err := c.restartDock(tt.ctx)
+			if len(tt.wantErr) == 0 && err != nil {
+				t.Fatalf("restartDock() error = %v", err)
+			}
+			for _, want := range tt.wantErr {
+				if !errors.Is(err, want) {
+					t.Fatalf("restartDock() error = %v, want %v", err, want)
+				}
+			}
+			if tt.wantRun != nil {
+				var got []string
+				for _, cmd := range fake.Calls() {
+					if cmd.Name == "pgrep" {
+						got = append(got, cmd.Name)
+					} else {
+						got = append(got, cmd.String())
+					}
+				}
+				if !reflect.DeepEqual(got, tt.wantRun) {
+					t.Errorf("restartDock() ran %q, want %q", got, tt.wantRun)
+				}
+			}
+		})
+	}
+}
+
+func TestConfig_removeDatabaseFiles(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name    string
+		rebuild bool // the restarted Dock rebuilds the database
+		ctx     context.Context
+		wantErr []error
+	}{
+		{name: "rebuilt", rebuild: true},
+		{name: "not rebuilt", wantErr: []error{ErrDockRestart, database.ErrDBNotReady}},
+		{name: "interrupted", ctx: cancelled, wantErr: []error{context.Canceled}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeDock(t, runnertest.Result{}, 100, 200)
+			file := testdb.New(t, testdb.Generate(3, 35))
+			for _, suffix := range []string{"-wal", "-shm"} {
+				if err := os.WriteFile(file+suffix, nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.rebuild {
+				timer := time.AfterFunc(50*time.Millisecond, func() {
+					if err := testdb.Create(filepath.Join(filepath.Dir(file), "db"), testdb.Generate(3, 35)); err != nil {
+						t.Error(err)
+					}
+				})
+				defer timer.Stop()
+			}
+			c := &Config{DockTimeout: time.Second}
+			if !tt.rebuild {
+				c.DockTimeout = 100 * time.Millisecond
+			}
+
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			err := c.removeDatabaseFiles(ctx, file)
+			if len(tt.wantErr) == 0 && err != nil {
+				t.Fatalf("removeDatabaseFiles() error = %v", err)
+			}
+			for _, want := range tt.wantErr {
+				if !errors.Is(err, want) {
+					t.Fatalf("removeDatabaseFiles() error = %v, want %v", err, want)
+				}
+			}
+			for _, suffix := range []string{"-wal", "-shm"} {
+				if _, err := os.Stat(file + suffix); !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("removeDatabaseFiles() left %s", file+suffix)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/apex/log"
+	"github.com/blacktop/lporg/internal/database"
+)
+
+// Validate checks the config files and prints every problem found as file:line:col: message
+func Validate(w io.Writer, files []string, asJSON bool) error {
+	diags := []database.Diagnostic{}
+	for _, file := range files {
+		found, err := database.Validate(file)
+		if err != nil {
+			return err
+		}
+		if len(found) == 0 {
+			log.WithField("path", file).Debug("config is valid")
+		}
+		diags = append(diags, found...)
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(diags); err != nil {
+			return err
+		}
+	} else {
+		for _, d := range diags {
+			fmt.Fprintln(w, d)
+		}
+	}
+
+	if len(diags) > 0 {
+		return fmt.Errorf("%w: found %d problem(s) in config", database.ErrConfigInvalid, len(diags))
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// itemBatchSize is the number of items written per UPDATE statement. Each item uses 7 bind
+// parameters and every row scans the CASE branches, so larger batches get slower.
+const itemBatchSize = 100
+
+// appIndex holds the installed apps and their items so placing apps does not query the database per app
+type appIndex struct {
+	byBundle map[string]App
+	byTitle  map[string]App
+	items    map[int]Item
+}
+
+// loadAppIndex preloads every app and app item in the database
+func (lp *LaunchPad) loadAppIndex() (*appIndex, error) {
+	var (
+		apps  []App
+		items []Item
+	)
+	if err := lp.DB.Select("item_id, title, bundleid").Order("item_id").Find(&apps).Error; err != nil {
+		return nil, fmt.Errorf("query all apps failed: %w", err)
+	}
+	if err := lp.DB.Where("rowid IN (?)", lp.DB.Table("apps").Select("item_id")).Find(&items).Error; err != nil {
+		return nil, fmt.Errorf("query all app items failed: %w", err)
+	}
+
+	idx := newAppIndex(apps)
+	idx.items = make(map[int]Item, len(items))
+	for _, item := range items {
+		idx.items[item.ID] = item
+	}
+	return idx, nil
+}
+
+// newAppIndex indexes the apps by bundle identifier and title. The first app wins like a
+// First query would.
+func newAppIndex(apps []App) *appIndex {
+	idx := &appIndex{
+		byBundle: make(map[string]App, len(apps)),
+		byTitle:  make(map[string]App, len(apps)),
+	}
+	for _, app := range apps {
+		if _, ok := idx.byBundle[app.BundleID]; !ok && len(app.BundleID) > 0 {
+			idx.byBundle[app.BundleID] = app
+		}
+		if _, ok := idx.byTitle[app.Title]; !ok {
+			idx.byTitle[app.Title] = app
+		}
+	}
+	return idx
+}
+
+// app returns the installed app the config reference resolves to,
+// matching on bundle identifier first and falling back to the title
+func (idx *appIndex) app(ref AppRef) (App, bool) {
+	if app, ok := idx.byBundle[ref.Bundle]; ok && len(ref.Bundle) > 0 {
+		return app, true
+	}
+	if app, ok := idx.byTitle[ref.Title]; ok && len(ref.Title) > 0 {
+		return app, true
+	}
+	return App{}, false
+}
+
+// find returns the app item the config reference resolves to
+func (idx *appIndex) find(ref AppRef) (Item, error) {
+	app, ok := idx.app(ref)
+	if !ok {
+		return Item{}, fmt.Errorf("app '%s' not found", ref)
+	}
+	item, ok := idx.items[app.ID]
+	if !ok {
+		return Item{}, fmt.Errorf("item not found for app ID %d", app.ID)
+	}
+	return item, nil
+}
+
+// saveItems writes the type, parent and ordering of the items in batched UPDATE statements
+func (lp *LaunchPad) saveItems(items []Item) error {
+	for start := 0; start < len(items); start += itemBatchSize {
+		batch := items[start:min(start+itemBatchSize, len(items))]
+
+		var (
+			types, parents, orderings strings.Builder
+			typeArgs, parentArgs      []any
+			orderingArgs, ids         []any
+		)
+		// CASE takes the first matching WHEN, so walk the batch backwards to let the last
+		// entry for an item win like the separate per-item updates did
+		for idx := len(batch) - 1; idx >= 0; idx-- {
+			item := batch[idx]
+			types.WriteString(" WHEN ? THEN ?")
+			parents.WriteString(" WHEN ? THEN ?")
+			orderings.WriteString(" WHEN ? THEN ?")
+			typeArgs = append(typeArgs, item.ID, item.Type)
+			parentArgs = append(parentArgs, item.ID, item.ParentID)
+			orderingArgs = append(orderingArgs, item.ID, item.Ordering)
+			ids = append(ids, item.ID)
+		}
+
+		query := fmt.Sprintf("UPDATE items SET type = CASE rowid%s END, parent_id = CASE rowid%s END, ordering = CASE rowid%s END WHERE rowid IN (?%s)",
+			types.String(), parents.String(), orderings.String(), strings.Repeat(",?", len(batch)-1))
+		args := append(append(append(typeArgs, parentArgs...), orderingArgs...), ids...)
+		if err := lp.DB.Exec(query, args...).Error; err != nil {
+			return fmt.Errorf("failed to update %d items: %w", len(batch), err)
+		}
+	}
+	return nil
+}
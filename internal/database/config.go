@@ -1,69 +1,78 @@
 package database
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 
 	"github.com/apex/log"
 	"github.com/blacktop/lporg/internal/utils"
-	"github.com/mitchellh/mapstructure"
-	"github.com/pkg/errors"
 	yaml "gopkg.in/yaml.v3"
 )
 
 // Config is the Launchpad config
 type Config struct {
-	Apps    Apps    `yaml:"apps" json:"apps,omitempty"`
-	Widgets Apps    `yaml:"widgets" json:"widgets,omitempty"`
-	Dock    Dock    `yaml:"dock_items" json:"dock_items,omitempty"  mapstructure:"dock_items"`
-	Desktop Desktop `yaml:"desktop" json:"desktop,omitempty"  mapstructure:"desktop"`
+	Apps    Apps      `yaml:"apps" json:"apps,omitempty"`
+	Widgets Apps      `yaml:"widgets" json:"widgets,omitempty"`
+	Dock    Dock      `yaml:"dock_items" json:"dock_items,omitempty"`
+	Desktop Desktop   `yaml:"desktop" json:"desktop,omitempty"`
+	Rules   []Rule    `yaml:"rules,omitempty" json:"rules,omitempty"`
+	Grid    Grid      `yaml:"grid,omitempty" json:"grid,omitempty"`
+	Missing Placement `yaml:"missing,omitempty" json:"missing,omitempty"`
 }
 
 // GetFolderContainingApp returns the folder name that contains the app
-func (c Config) GetFolderContainingApp(app string) (string, error) {
+func (c Config) GetFolderContainingApp(app App) (string, error) {
+	var byTitle string
 	for _, page := range c.Apps.Pages {
 		for _, item := range page.Items {
-			switch item.(type) {
-			case string:
+			folder := item.Folder
+			if folder == nil {
 				continue
-			default:
-				var folder AppFolder
-				if err := mapstructure.Decode(item, &folder); err != nil {
-					return "", errors.Wrap(err, "mapstructure unable to decode config folder")
-				}
-				for _, page := range folder.Pages {
-					for _, item := range page.Items {
-						if item == app {
-							return folder.Name, nil
-						}
+			}
+			for _, page := range folder.Pages {
+				for _, ref := range page.Items {
+					if len(ref.Bundle) > 0 && ref.Bundle == app.BundleID {
+						return folder.Name, nil
+					}
+					if len(byTitle) == 0 && ref.Title == app.Title {
+						byTitle = folder.Name
 					}
 				}
 			}
 		}
 	}
-	return "", fmt.Errorf("unable to find folder containing app %s", app)
+	if len(byTitle) > 0 {
+		return byTitle, nil
+	}
+	return "", fmt.Errorf("unable to find folder containing app %s", app.Title)
 }
 
 // Verify that the config is valid
 func (c Config) Verify() error {
+	for _, rule := range c.Rules {
+		if err := rule.compile(); err != nil {
+			return err
+		}
+	}
 	for _, page := range c.Apps.Pages {
 		for _, item := range page.Items {
-			switch item.(type) {
-			case string:
+			folder := item.Folder
+			if folder == nil {
 				continue
-			default:
-				var folder AppFolder
-				if err := mapstructure.Decode(item, &folder); err != nil {
-					return fmt.Errorf("mapstructure unable to decode config folder")
-				}
-				if len(folder.Pages) > 0 {
-					if len(folder.Pages[0].Items) == 0 { // verify that all folders contain at least 1 item
-						return fmt.Errorf("folder %s must contain at least 1 item to be valid", folder.Name)
-					}
+			}
+			if len(folder.Pages) > 0 {
+				if len(folder.Pages[0].Items) == 0 { // verify that all folders contain at least 1 item
+					return fmt.Errorf("folder %s must contain at least 1 item to be valid", folder.Name)
 				}
 			}
 		}
 	}
+	if c.Dock.Settings != nil {
+		if err := c.Dock.Settings.Verify(); err != nil {
+			return fmt.Errorf("invalid dock settings: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -72,22 +81,285 @@ type Apps struct {
 	Pages []Page `yaml:"pages" json:"pages,omitempty"`
 }
 
+// Count returns the number of apps on the pages and in the folders
+func (a Apps) Count() int {
+	var count int
+	for _, page := range a.Pages {
+		for _, item := range page.Items {
+			if item.Folder == nil {
+				count++
+				continue
+			}
+			for _, fpage := range item.Folder.Pages {
+				count += len(fpage.Items)
+			}
+		}
+	}
+	return count
+}
+
+// Paginate splits pages and folder pages that hold more items than fit on the grid
+// into additional pages placed right after them and renumbers all pages
+func (a *Apps) Paginate(grid Grid) error {
+	var pages []Page
+	for _, page := range a.Pages {
+		for _, item := range page.Items {
+			folder := item.Folder
+			if folder == nil {
+				continue
+			}
+			var fpages []FolderPage
+			for _, fpage := range folder.Pages {
+				for len(fpage.Items) > grid.FolderCapacity() {
+					utils.Indent(log.WithFields(log.Fields{"folder": folder.Name, "number": fpage.Number}).Warn, 3)("folder page is full, overflowing to a new page")
+					fpages = append(fpages, FolderPage{Items: fpage.Items[:grid.FolderCapacity():grid.FolderCapacity()]})
+					fpage.Items = fpage.Items[grid.FolderCapacity():]
+				}
+				fpages = append(fpages, FolderPage{Items: fpage.Items})
+			}
+			for fidx := range fpages {
+				fpages[fidx].Number = fidx + 1
+			}
+			folder.Pages = fpages
+		}
+		for len(page.Items) > grid.PageCapacity() {
+			utils.Indent(log.WithField("number", page.Number).Warn, 3)("page is full, overflowing to a new page")
+			pages = append(pages, Page{Items: page.Items[:grid.PageCapacity():grid.PageCapacity()]})
+			page.Items = page.Items[grid.PageCapacity():]
+		}
+		pages = append(pages, Page{Items: page.Items})
+	}
+	for idx := range pages {
+		pages[idx].Number = idx + 1
+	}
+	a.Pages = pages
+	return nil
+}
+
 // Page is a launchpad page object
 type Page struct {
-	Number int   `yaml:"number" json:"number"`
-	Items  []any `yaml:"items,omitempty" json:"items,omitempty"`
+	Number int        `yaml:"number" json:"number"`
+	Items  []PageItem `yaml:"items,omitempty" json:"items,omitempty"`
+}
+
+// PageItem is an item on a launchpad page. Exactly one of its fields is set.
+type PageItem struct {
+	App    *AppRef
+	Folder *AppFolder
+}
+
+// AppItem returns a page item for the app
+func AppItem(ref AppRef) PageItem {
+	return PageItem{App: &ref}
+}
+
+// FolderItem returns a page item for the folder
+func FolderItem(folder AppFolder) PageItem {
+	return PageItem{Folder: &folder}
+}
+
+// Name returns the app title or the folder name
+func (i PageItem) Name() string {
+	if i.Folder != nil {
+		return i.Folder.Name
+	}
+	if i.App != nil {
+		return i.App.String()
+	}
+	return ""
+}
+
+func (i PageItem) String() string {
+	if i.Folder != nil {
+		return fmt.Sprintf("folder '%s'", i.Folder.Name)
+	}
+	return i.Name()
+}
+
+// UnmarshalYAML decodes an app title, a {bundle, title} mapping or a {folder, pages} mapping
+func (i *PageItem) UnmarshalYAML(value *yaml.Node) error {
+	*i = PageItem{}
+	switch value.Kind {
+	case yaml.ScalarNode:
+		i.App = &AppRef{Title: value.Value}
+	case yaml.MappingNode:
+		for idx := 0; idx < len(value.Content); idx += 2 {
+			if value.Content[idx].Value == "folder" {
+				var folder AppFolder
+				if err := value.Decode(&folder); err != nil {
+					return err
+				}
+				i.Folder = &folder
+				return nil
+			}
+		}
+		var ref AppRef
+		if err := value.Decode(&ref); err != nil {
+			return err
+		}
+		i.App = &ref
+	default:
+		return fmt.Errorf("line %d: unsupported config item", value.Line)
+	}
+	return nil
+}
+
+// MarshalYAML encodes the app or the folder
+func (i PageItem) MarshalYAML() (any, error) {
+	if i.Folder != nil {
+		return i.Folder, nil
+	}
+	if i.App != nil {
+		return i.App, nil
+	}
+	return nil, fmt.Errorf("empty config item")
+}
+
+// UnmarshalJSON decodes an app title, a {bundle, title} object or a {folder, pages} object
+func (i *PageItem) UnmarshalJSON(data []byte) error {
+	*i = PageItem{}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err == nil {
+		if _, ok := fields["folder"]; ok {
+			var folder AppFolder
+			if err := json.Unmarshal(data, &folder); err != nil {
+				return err
+			}
+			i.Folder = &folder
+			return nil
+		}
+	}
+	var ref AppRef
+	if err := json.Unmarshal(data, &ref); err != nil {
+		return err
+	}
+	if len(ref.Title) == 0 && len(ref.Bundle) == 0 {
+		return fmt.Errorf("config app must have a title or a bundle: %s", data)
+	}
+	i.App = &ref
+	return nil
+}
+
+// MarshalJSON encodes the app or the folder
+func (i PageItem) MarshalJSON() ([]byte, error) {
+	if i.Folder != nil {
+		return json.Marshal(i.Folder)
+	}
+	if i.App != nil {
+		return json.Marshal(i.App)
+	}
+	return nil, fmt.Errorf("empty config item")
 }
 
 // AppFolder is a launchpad folder object
 type AppFolder struct {
-	Name  string       `yaml:"folder" json:"folder,omitempty" mapstructure:"folder"`
+	Name  string       `yaml:"folder" json:"folder,omitempty"`
 	Pages []FolderPage `yaml:"pages,omitempty" json:"pages,omitempty"`
 }
 
 // FolderPage is a launchpad folder page object
 type FolderPage struct {
 	Number int      `yaml:"number,omitempty" json:"number"`
-	Items  []string `yaml:"items,omitempty" json:"items,omitempty"`
+	Items  []AppRef `yaml:"items,omitempty" json:"items,omitempty"`
+}
+
+// AppRef references an app by its bundle identifier and/or its title.
+// The bundle identifier takes precedence, the title is used as a fallback.
+type AppRef struct {
+	Title  string `yaml:"title,omitempty" json:"title,omitempty"`
+	Bundle string `yaml:"bundle,omitempty" json:"bundle,omitempty"`
+}
+
+func (r AppRef) String() string {
+	if len(r.Title) > 0 {
+		return r.Title
+	}
+	return r.Bundle
+}
+
+type appRef AppRef // avoids recursing into the custom (un)marshalers
+
+// UnmarshalYAML decodes an app title or a {bundle, title} mapping
+func (r *AppRef) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		r.Title = value.Value
+		return nil
+	}
+	if err := value.Decode((*appRef)(r)); err != nil {
+		return err
+	}
+	if len(r.Title) == 0 && len(r.Bundle) == 0 {
+		return fmt.Errorf("line %d: config app must have a title or a bundle", value.Line)
+	}
+	return nil
+}
+
+// MarshalYAML encodes apps without a bundle identifier as their plain title
+func (r AppRef) MarshalYAML() (any, error) {
+	if len(r.Bundle) == 0 {
+		return r.Title, nil
+	}
+	return appRef(r), nil
+}
+
+// UnmarshalJSON decodes an app title or a {bundle, title} object
+func (r *AppRef) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &r.Title); err == nil {
+		return nil
+	}
+	return json.Unmarshal(data, (*appRef)(r))
+}
+
+// MarshalJSON encodes apps without a bundle identifier as their plain title
+func (r AppRef) MarshalJSON() ([]byte, error) {
+	if len(r.Bundle) == 0 {
+		return json.Marshal(r.Title)
+	}
+	return json.Marshal(appRef(r))
+}
+
+// Launchpad's default grid dimensions
+const (
+	DefaultRows    = 5
+	DefaultColumns = 7
+)
+
+// Grid is the launchpad grid dimensions
+type Grid struct {
+	Rows          int `yaml:"rows,omitempty" json:"rows,omitempty"`
+	Columns       int `yaml:"columns,omitempty" json:"columns,omitempty"`
+	FolderRows    int `yaml:"folder_rows,omitempty" json:"folder_rows,omitempty"`
+	FolderColumns int `yaml:"folder_columns,omitempty" json:"folder_columns,omitempty"`
+}
+
+// WithDefaults fills in unset dimensions from fallback, then from Launchpad's defaults.
+// Folders use the page dimensions unless they are set.
+func (g Grid) WithDefaults(fallback Grid) Grid {
+	pick := func(vals ...int) int {
+		for _, v := range vals {
+			if v > 0 {
+				return v
+			}
+		}
+		return 0
+	}
+	g.Rows = pick(g.Rows, fallback.Rows, DefaultRows)
+	g.Columns = pick(g.Columns, fallback.Columns, DefaultColumns)
+	g.FolderRows = pick(g.FolderRows, fallback.FolderRows, g.Rows)
+	g.FolderColumns = pick(g.FolderColumns, fallback.FolderColumns, g.Columns)
+	return g
+}
+
+// PageCapacity returns the number of items that fit on a launchpad page
+func (g Grid) PageCapacity() int {
+	g = g.WithDefaults(Grid{})
+	return g.Rows * g.Columns
+}
+
+// FolderCapacity returns the number of apps that fit on a folder page
+func (g Grid) FolderCapacity() int {
+	g = g.WithDefaults(Grid{})
+	return g.FolderRows * g.FolderColumns
 }
 
 // Desktop is the desktop object
@@ -140,6 +412,38 @@ type DockSettings struct {
 	TileSize              any  `yaml:"tilesize" json:"tilesize,omitempty"`
 }
 
+// Verify checks that the dock icon sizes are between 16 and 128
+func (s DockSettings) Verify() error {
+	for _, size := range []struct {
+		name  string
+		value any
+	}{
+		{"largesize", s.LargeSize},
+		{"tilesize", s.TileSize},
+	} {
+		if size.value == nil {
+			continue
+		}
+		var v float64
+		switch n := size.value.(type) {
+		case int:
+			v = float64(n)
+		case int64:
+			v = float64(n)
+		case uint64:
+			v = float64(n)
+		case float64:
+			v = n
+		default:
+			return fmt.Errorf("%s must be a number: %v", size.name, size.value)
+		}
+		if v < 16 || v > 128 {
+			return fmt.Errorf("%s must be between 16 and 128: %v", size.name, size.value)
+		}
+	}
+	return nil
+}
+
 // Dock is the launchpad dock config object
 type Dock struct {
 	Apps     []string      `yaml:"apps,omitempty" json:"apps,omitempty"`
@@ -154,18 +458,15 @@ func LoadConfig(filename string) (Config, error) {
 	utils.Indent(log.WithField("path", filename).Info, 2)("parsing launchpad config YAML")
 	data, err := os.ReadFile(filename)
 	if err != nil {
-		utils.Indent(log.WithError(err).WithField("path", filename).Fatal, 3)("config file not found")
-		return conf, err
+		return conf, fmt.Errorf("%w: %v", ErrConfigNotFound, err)
 	}
 
-	err = yaml.Unmarshal(data, &conf)
-	if err != nil {
-		utils.Indent(log.WithError(err).WithField("path", filename).Fatal, 3)("unmarshalling yaml failed")
-		return conf, err
+	if err := yaml.Unmarshal(data, &conf); err != nil {
+		return conf, fmt.Errorf("%w: unmarshalling yaml failed: %v", ErrConfigInvalid, err)
 	}
 
 	if err := conf.Verify(); err != nil {
-		return conf, fmt.Errorf("config verification failed: %v", err)
+		return conf, fmt.Errorf("%w: config verification failed: %v", ErrConfigInvalid, err)
 	}
 
 	return conf, nil
@@ -0,0 +1,191 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+func app(title string) PageItem {
+	return AppItem(AppRef{Title: title})
+}
+
+func TestApps_Paginate(t *testing.T) {
+	tests := []struct {
+		name string
+		apps Apps
+		grid Grid
+		want Apps
+	}{
+		{
+			name: "overflow page and folder",
+			apps: Apps{Pages: []Page{
+				{Number: 1, Items: []PageItem{app("A"), app("B"), app("C")}},
+				{Number: 2, Items: []PageItem{
+					FolderItem(AppFolder{Name: "F", Pages: []FolderPage{{Number: 1, Items: []AppRef{{Title: "D"}, {Title: "E"}, {Title: "F"}}}}}),
+				}},
+			}},
+			grid: Grid{Rows: 1, Columns: 2, FolderRows: 1, FolderColumns: 2},
+			want: Apps{Pages: []Page{
+				{Number: 1, Items: []PageItem{app("A"), app("B")}},
+				{Number: 2, Items: []PageItem{app("C")}},
+				{Number: 3, Items: []PageItem{
+					FolderItem(AppFolder{Name: "F", Pages: []FolderPage{
+						{Number: 1, Items: []AppRef{{Title: "D"}, {Title: "E"}}},
+						{Number: 2, Items: []AppRef{{Title: "F"}}},
+					}}),
+				}},
+			}},
+		},
+		{
+			name: "default grid",
+			apps: Apps{Pages: []Page{{Number: 1, Items: []PageItem{app("A")}}}},
+			want: Apps{Pages: []Page{{Number: 1, Items: []PageItem{app("A")}}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.apps.Paginate(tt.grid); err != nil {
+				t.Fatalf("Apps.Paginate() error = %v", err)
+			}
+			if !reflect.DeepEqual(tt.apps, tt.want) {
+				t.Errorf("Apps.Paginate() = %v, want %v", tt.apps, tt.want)
+			}
+		})
+	}
+}
+
+func TestGrid_WithDefaults(t *testing.T) {
+	tests := []struct {
+		name     string
+		grid     Grid
+		fallback Grid
+		want     Grid
+	}{
+		{
+			name: "launchpad defaults",
+			want: Grid{Rows: 5, Columns: 7, FolderRows: 5, FolderColumns: 7},
+		},
+		{
+			name:     "dock prefs fallback",
+			grid:     Grid{Columns: 9},
+			fallback: Grid{Rows: 6, Columns: 8},
+			want:     Grid{Rows: 6, Columns: 9, FolderRows: 6, FolderColumns: 9},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.grid.WithDefaults(tt.fallback); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Grid.WithDefaults() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPageItem_UnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    PageItem
+		wantErr bool
+	}{
+		{
+			name: "app title",
+			data: "Safari",
+			want: app("Safari"),
+		},
+		{
+			name: "app bundle",
+			data: "{bundle: com.apple.Safari, title: Safari}",
+			want: AppItem(AppRef{Title: "Safari", Bundle: "com.apple.Safari"}),
+		},
+		{
+			name: "folder",
+			data: "{folder: Browsers, pages: [{number: 1, items: [Safari, {bundle: org.mozilla.firefox}]}]}",
+			want: FolderItem(AppFolder{Name: "Browsers", Pages: []FolderPage{
+				{Number: 1, Items: []AppRef{{Title: "Safari"}, {Bundle: "org.mozilla.firefox"}}},
+			}}),
+		},
+		{
+			name:    "app without title or bundle",
+			data:    "{name: Safari}",
+			wantErr: true,
+		},
+		{
+			name:    "empty app",
+			data:    "{}",
+			wantErr: true,
+		},
+		{
+			name:    "empty app in folder",
+			data:    "{folder: Browsers, pages: [{items: [Safari, {}]}]}",
+			wantErr: true,
+		},
+		{
+			name:    "list",
+			data:    "[Safari]",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got PageItem
+			if err := yaml.Unmarshal([]byte(tt.data), &got); (err != nil) != tt.wantErr {
+				t.Errorf("PageItem.UnmarshalYAML() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PageItem.UnmarshalYAML() = %v, want %v", got, tt.want)
+			}
+			// round trip through JSON
+			data, err := json.Marshal(got)
+			if err != nil {
+				t.Fatalf("PageItem.MarshalJSON() error = %v", err)
+			}
+			var back PageItem
+			if err := json.Unmarshal(data, &back); err != nil {
+				t.Fatalf("PageItem.UnmarshalJSON() error = %v", err)
+			}
+			if !reflect.DeepEqual(back, tt.want) {
+				t.Errorf("PageItem JSON round trip = %v, want %v", back, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_errors(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	tests := []struct {
+		name    string
+		file    string
+		wantErr error
+	}{
+		{name: "missing", file: filepath.Join(dir, "missing.yml"), wantErr: ErrConfigNotFound},
+		{name: "bad yaml", file: write("bad.yml", "apps: [\n"), wantErr: ErrConfigInvalid},
+		{name: "bad dock settings", file: write("dock.yml", "dock_items:\n  settings:\n    tilesize: 1000\n"), wantErr: ErrConfigInvalid},
+		{name: "valid", file: write("valid.yml", "apps:\n  pages:\n    - number: 1\n      items: [Safari]\n")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadConfig(tt.file)
+			if !errors.Is(err, tt.wantErr) || (err != nil) != (tt.wantErr != nil) {
+				t.Errorf("LoadConfig() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
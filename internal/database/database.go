@@ -8,113 +8,150 @@ import (
 	"github.com/apex/log"
 	"github.com/blacktop/lporg/internal/utils"
 	"github.com/google/uuid"
-	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
 	"golang.org/x/exp/slices"
 	"gorm.io/gorm"
 )
 
-// GetMissing returns a list of the rest of the apps not in the config
-func (lp *LaunchPad) GetMissing(apps *Apps, appType int) error {
+// MissingReport lists where the config and the installed apps disagree
+type MissingReport struct {
+	Unlisted     []string `json:"unlisted,omitempty"`      // installed apps that are not in the config
+	Uninstalled  []string `json:"uninstalled,omitempty"`   // config apps that are not installed
+	EmptyFolders []string `json:"empty_folders,omitempty"` // config folders with no installed apps left
+}
+
+// Empty returns true if the config and the installed apps agree
+func (r *MissingReport) Empty() bool {
+	return len(r.Unlisted) == 0 && len(r.Uninstalled) == 0 && len(r.EmptyFolders) == 0
+}
+
+func (r *MissingReport) String() string {
+	return fmt.Sprintf("%d installed apps not in config, %d config apps not installed, %d empty folders",
+		len(r.Unlisted), len(r.Uninstalled), len(r.EmptyFolders))
+}
+
+// GetMissing returns a copy of apps without the apps that are not installed and
+// with the installed apps that are not in the config placed by the rules and the
+// missing apps policy, along with a report of the differences
+func (lp *LaunchPad) GetMissing(apps Apps, appType int) (Apps, *MissingReport, error) {
+	var report MissingReport
 
 	// get all apps from database
 	switch appType {
 	case ApplicationType:
-		var apps []App
 		err := lp.DB.Table("apps").
-			Select("apps.item_id, apps.title").
+			Select("apps.item_id, apps.title, apps.bundleid, apps.category_id").
 			Joins("left join items on items.rowid = apps.item_id").
 			Not("items.parent_id = ?", 6).
-			Scan(&apps).Error
+			Scan(&lp.dbApps).Error
 		if err != nil {
-			return fmt.Errorf("query all apps failed: %w", err)
-		}
-		for _, app := range apps {
-			lp.dbApps = append(lp.dbApps, app.Title)
+			return Apps{}, nil, fmt.Errorf("query all apps failed: %w", err)
 		}
 	default:
-		return fmt.Errorf("GetMissing: unsupported app type: %d", appType)
+		return Apps{}, nil, fmt.Errorf("GetMissing: unsupported app type: %d", appType)
 	}
 
-	sort.Strings(lp.dbApps)
+	sort.Slice(lp.dbApps, func(i, j int) bool { return lp.dbApps[i].Title < lp.dbApps[j].Title })
+
+	// get all app categories and folder rules
+	var categories []Category
+	if err := lp.DB.Find(&categories).Error; err != nil {
+		return Apps{}, nil, fmt.Errorf("query all categories failed: %w", err)
+	}
+	categoryUTIs := make(map[int]string, len(categories))
+	for _, category := range categories {
+		categoryUTIs[int(category.ID)] = category.UTI
+	}
+	rules := slices.Clone(lp.Config.Rules)
+	for idx := range rules {
+		if err := rules[idx].compile(); err != nil {
+			return Apps{}, nil, err
+		}
+	}
 
-	// get all apps from config file
+	// copy the apps from the config file that are installed
+	installed := newAppIndex(lp.dbApps)
+	out := Apps{Pages: make([]Page, 0, len(apps.Pages))}
+	confApps := make(map[int]bool)
 	for _, page := range apps.Pages {
+		outPage := Page{Number: page.Number, Items: make([]PageItem, 0, len(page.Items))}
 		for _, item := range page.Items {
-			switch item.(type) {
-			case string:
-				lp.confApps = append(lp.confApps, item.(string))
-			default:
-				var folder AppFolder
-				if err := mapstructure.Decode(item, &folder); err != nil {
-					return fmt.Errorf("mapstructure unable to decode config folder: %w", err)
+			app, folder := item.App, item.Folder
+			if app != nil {
+				if a, ok := installed.app(*app); ok {
+					confApps[a.ID] = true
+					outPage.Items = append(outPage.Items, item)
+				} else {
+					utils.Indent(log.WithField("app", app).Warn, 3)("found app in config that are is not on system")
+					report.Uninstalled = append(report.Uninstalled, app.String())
 				}
-				lp.confFolders = append(lp.confFolders, folder.Name)
-				for _, fpage := range folder.Pages {
-					for _, fitem := range fpage.Items {
-						lp.confApps = append(lp.confApps, fitem)
+				continue
+			}
+			outFolder := AppFolder{Name: folder.Name}
+			for _, fpage := range folder.Pages {
+				outFPage := FolderPage{Number: len(outFolder.Pages) + 1}
+				for _, fitem := range fpage.Items {
+					if a, ok := installed.app(fitem); ok {
+						confApps[a.ID] = true
+						outFPage.Items = append(outFPage.Items, fitem)
+					} else {
+						utils.Indent(log.WithField("app", fitem).Warn, 3)("found app in config that are is not on system")
+						report.Uninstalled = append(report.Uninstalled, fitem.String())
 					}
 				}
+				if len(outFPage.Items) > 0 {
+					outFolder.Pages = append(outFolder.Pages, outFPage)
+				}
+			}
+			if len(outFolder.Pages) == 0 {
+				utils.Indent(log.WithField("folder", folder.Name).Warn, 3)("dropping folder with no installed apps")
+				report.EmptyFolders = append(report.EmptyFolders, folder.Name)
+				continue
 			}
+			lp.confFolders = append(lp.confFolders, folder.Name)
+			outPage.Items = append(outPage.Items, FolderItem(outFolder))
 		}
+		out.Pages = append(out.Pages, outPage)
 	}
 
-	sort.Strings(lp.confApps)
-
+	var unplaced []App
 	for _, app := range lp.dbApps {
-		if !slices.Contains(lp.confApps, app) {
-			utils.Indent(log.WithField("app", app).Warn, 3)("found installed apps that are not in supplied config")
-			if len(apps.Pages[len(apps.Pages)-1].Items) < 35 {
-				apps.Pages[len(apps.Pages)-1].Items = append(apps.Pages[len(apps.Pages)-1].Items, app)
-			} else {
-				newPage := Page{
-					Number: len(apps.Pages) + 1,
-					Items:  []any{app},
+		if !confApps[app.ID] {
+			utils.Indent(log.WithField("app", app.Title).Warn, 3)("found installed apps that are not in supplied config")
+			report.Unlisted = append(report.Unlisted, app.Title)
+			if folder, ok := route(rules, app, categoryUTIs[app.CategoryID]); ok {
+				utils.Indent(log.WithFields(log.Fields{"app": app.Title, "folder": folder}).Info, 4)("adding app to folder by rule")
+				created, err := out.addToFolder(folder, app.Ref(), lp.Config.Grid)
+				if err != nil {
+					return Apps{}, nil, err
+				}
+				if created {
+					lp.confFolders = append(lp.confFolders, folder)
 				}
-				apps.Pages = append(apps.Pages, newPage)
+				continue
 			}
+			unplaced = append(unplaced, app)
 		}
 	}
 
-	// check all apps from config file exist on system
-	for idx, page := range apps.Pages {
-		tmp := []any{}
-		for _, item := range page.Items {
-			switch item.(type) {
-			case string:
-				if !slices.Contains(lp.dbApps, item.(string)) {
-					utils.Indent(log.WithField("app", item.(string)).Warn, 3)("found app in config that are is not on system")
-				} else {
-					tmp = append(tmp, item)
-				}
-			default:
-				var folder AppFolder
-				if err := mapstructure.Decode(item, &folder); err != nil {
-					return fmt.Errorf("mapstructure unable to decode config folder: %w", err)
-				}
-				for fpIdx, fpage := range folder.Pages {
-					ftmp := []string{}
-					for _, fitem := range fpage.Items {
-						if !slices.Contains(lp.dbApps, fitem) {
-							utils.Indent(log.WithField("app", fitem).Warn, 3)("found app in config that are is not on system")
-						} else {
-							ftmp = append(ftmp, fitem)
-						}
-					}
-					switch item.(type) {
-					case AppFolder:
-						item.(AppFolder).Pages[fpIdx].Items = ftmp
-					default:
-						item.(map[string]any)["pages"].([]any)[fpIdx].(map[string]any)["items"] = ftmp
-					}
-				}
-				tmp = append(tmp, item)
-			}
+	// place the apps no rule matched according to the missing apps policy
+	switch lp.Config.Missing.Policy {
+	case PlaceFolder:
+		if len(unplaced) > 0 && !slices.Contains(lp.confFolders, lp.Config.Missing.Folder) {
+			lp.confFolders = append(lp.confFolders, lp.Config.Missing.Folder)
 		}
-		apps.Pages[idx].Items = tmp
+	case PlaceIgnore:
+		lp.ignored = make(map[int]bool, len(unplaced))
+		for _, app := range unplaced {
+			utils.Indent(log.WithField("app", app.Title).Info, 4)("leaving app where launchpad puts it")
+			lp.ignored[app.ID] = true
+		}
+	}
+	if err := out.place(lp.Config.Missing, unplaced, lp.Config.Grid); err != nil {
+		return Apps{}, nil, err
 	}
 
-	return nil
+	return out, &report, nil
 }
 
 // ClearGroups clears out items related to groups
@@ -130,24 +167,31 @@ func (lp *LaunchPad) ClearGroups() error {
 
 // FlattenApps sets all the apps to the root page
 func (lp *LaunchPad) FlattenApps() error {
-	var apps []App
-
-	if err := lp.DB.Find(&apps).Error; err != nil {
-		return fmt.Errorf("query all apps failed: %w", err)
+	index, err := lp.loadAppIndex()
+	if err != nil {
+		return err
 	}
 
-	lp.DisableTriggers()
+	if err := lp.DisableTriggers(); err != nil {
+		return err
+	}
 
 	utils.Indent(log.Info, 2)("flattening out apps")
-	for idx, app := range apps {
-		if err := lp.updateItem(app.Title, ApplicationType, lp.rootPage, idx); err != nil {
-			return fmt.Errorf("failed to update app '%s': %w", app.Title, err)
-		}
+	items := make([]Item, 0, len(index.items))
+	for _, item := range index.items {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	for idx := range items {
+		items[idx].Type = ApplicationType
+		items[idx].ParentID = lp.rootPage
+		items[idx].Ordering = idx
+	}
+	if err := lp.saveItems(items); err != nil {
+		return fmt.Errorf("failed to flatten apps: %w", err)
 	}
 
-	lp.EnableTriggers()
-
-	return nil
+	return lp.EnableTriggers()
 }
 
 // AddRootsAndHoldingPages adds back in the RootPage and HoldingPage defaults
@@ -253,49 +297,83 @@ func (lp *LaunchPad) createNewFolderPage(rowID, folderPageParentID, folderPageNu
 	return nil
 }
 
+// findApp returns the app the config reference resolves to,
+// matching on bundle identifier first and falling back to the title
+func (lp *LaunchPad) findApp(ref AppRef) (App, error) {
+	var a App
+	if len(ref.Bundle) > 0 {
+		err := lp.DB.Where("bundleid = ?", ref.Bundle).First(&a).Error
+		if err == nil {
+			return a, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) || len(ref.Title) == 0 {
+			return a, fmt.Errorf("app query failed for bundle '%s': %w", ref.Bundle, err)
+		}
+	}
+	if err := lp.DB.Where("title = ?", ref.Title).First(&a).Error; err != nil {
+		return a, fmt.Errorf("app query failed for '%s': %w", ref.Title, err)
+	}
+	return a, nil
+}
+
 // updateItem will add the apps/widgets to the correct page/folder
-func (lp *LaunchPad) updateItem(item string, itemType, parentID, ordering int) error {
+func (lp *LaunchPad) updateItem(item AppRef, itemType, parentID, ordering int) error {
 
-	i := Item{}
-	a := App{}
-	w := Widget{}
+	var id int
 
 	switch itemType {
 	case ApplicationType:
-		if err := lp.DB.Where("title = ?", item).First(&a).Error; err != nil {
-			return fmt.Errorf("app query failed for '%s': %w", item, err)
-		}
-		if err := lp.DB.Where("rowid = ?", a.ID).First(&i).Error; err != nil {
-			return fmt.Errorf("item query failed for app ID %d: %w", a.ID, err)
+		a, err := lp.findApp(item)
+		if err != nil {
+			return err
 		}
-		lp.DB.Model(&i).Association("App").Find(&i.App)
+		id = a.ID
 	case WidgetType:
-		if result := lp.DB.Where("title = ?", item).First(&w); result.Error != nil && errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		w := Widget{}
+		if result := lp.DB.Where("title = ?", item.Title).First(&w); result.Error != nil && errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			utils.Indent(log.WithField("app", item).Warn, 3)("widget not installed. SKIPPING...")
 			return nil
 		}
-		if err := lp.DB.Where("rowid = ?", w.ID).First(&i).Error; err != nil {
-			return fmt.Errorf("item query failed for wiget ID %d: %w", a.ID, err)
-		}
-		lp.DB.Model(&i).Association("Widget").Find(&i.Widget)
+		id = w.ID
 	default:
 		return fmt.Errorf("failed to update item: unknown item type: %d", itemType)
 	}
 
-	newItem := Item{
-		ID:       i.ID,
-		UUID:     i.UUID,
-		Flags:    i.Flags,
-		Type:     itemType,
-		ParentID: parentID,
-		Ordering: ordering,
+	result := lp.DB.Model(&Item{}).Where("rowid = ?", id).Updates(map[string]any{
+		"type":      itemType,
+		"parent_id": parentID,
+		"ordering":  ordering,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update item ID %d: %w", id, result.Error)
 	}
-
-	return lp.DB.Save(&newItem).Error
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("item query failed for ID %d: %w", id, gorm.ErrRecordNotFound)
+	}
+	return nil
 }
 
 func (lp *LaunchPad) ApplyConfig(config Apps, groupID, rootParentID int) error {
 
+	index, err := lp.loadAppIndex()
+	if err != nil {
+		return err
+	}
+
+	// app placements are collected and written in batches once all groups exist
+	var placed []Item
+	place := func(ref AppRef, parentID, ordering int) error {
+		item, err := index.find(ref)
+		if err != nil {
+			return err
+		}
+		item.Type = ApplicationType
+		item.ParentID = parentID
+		item.Ordering = ordering
+		placed = append(placed, item)
+		return nil
+	}
+
 	for _, page := range config.Pages {
 		groupID++
 		// create a new page
@@ -311,18 +389,14 @@ func (lp *LaunchPad) ApplyConfig(config Apps, groupID, rootParentID int) error {
 		pageParentID := groupID
 
 		for idx, item := range page.Items {
-			switch item.(type) {
-			case string:
+			switch {
+			case item.App != nil:
 				// add a flat item
-				if err := lp.updateItem(item.(string), ApplicationType, pageParentID, idx); err != nil {
+				if err := place(*item.App, pageParentID, idx); err != nil {
 					return errors.Wrap(err, "updateItem")
 				}
-			default:
-				var folder AppFolder
-				if err := mapstructure.Decode(item, &folder); err != nil {
-					return errors.Wrap(err, "mapstructure unable to decode config folder")
-				}
-
+			case item.Folder != nil:
+				folder := item.Folder
 				// create a new folder
 				groupID++
 				err := lp.createNewFolder(folder.Name, groupID, pageParentID, idx)
@@ -341,7 +415,7 @@ func (lp *LaunchPad) ApplyConfig(config Apps, groupID, rootParentID int) error {
 
 					// add all folder page items
 					for fidx, fitem := range fpage.Items {
-						if err := lp.updateItem(fitem, ApplicationType, groupID, fidx); err != nil {
+						if err := place(fitem, groupID, fidx); err != nil {
 							return errors.Wrap(err, "updateItem")
 						}
 					}
@@ -350,7 +424,7 @@ func (lp *LaunchPad) ApplyConfig(config Apps, groupID, rootParentID int) error {
 		}
 	}
 
-	return nil
+	return lp.saveItems(placed)
 }
 
 // // ApplyConfig places all the launchpad apps
@@ -426,23 +500,14 @@ func (lp *LaunchPad) ApplyConfig(config Apps, groupID, rootParentID int) error {
 // 	return nil
 // }
 
-func (lp *LaunchPad) addToFolder(appName, folderName string) error {
-	var a App
-	if err := lp.DB.Where("title = ?", appName).First(&a).Error; err != nil {
-		return fmt.Errorf("app query failed for '%s': %w", appName, err)
-	}
-	var app Item
-	if err := lp.DB.Where("rowid = ?", a.ID).First(&app).Error; err != nil {
-		return fmt.Errorf("item query failed for app ID %d: %w", a.ID, err)
-	}
-
+func (lp *LaunchPad) addToFolder(app App, folderName string) error {
 	var g Group
 	if err := lp.DB.Where("title = ?", folderName).First(&g).Error; err != nil {
 		return fmt.Errorf("group query failed for '%s': %w", folderName, err)
 	}
 	var folder Item
 	if err := lp.DB.Where("rowid = ?", g.ID).First(&folder).Error; err != nil {
-		return fmt.Errorf("item query failed for folder ID %d: %w", a.ID, err)
+		return fmt.Errorf("item query failed for folder ID %d: %w", g.ID, err)
 	}
 	var pages []Item
 	if err := lp.DB.Where("parent_id = ?", folder.ID).Find(&pages).Error; err != nil {
@@ -456,8 +521,8 @@ func (lp *LaunchPad) addToFolder(appName, folderName string) error {
 		return fmt.Errorf("failed to find apps for page '%d': %w", pages[0].ID, err)
 	}
 
-	if err := lp.updateItem(appName, ApplicationType, pages[0].ID, len(folderApps)); err != nil {
-		return fmt.Errorf("failed to add app '%s' to folder '%s': %w", appName, folderName, err)
+	if err := lp.updateItem(app.Ref(), ApplicationType, pages[0].ID, len(folderApps)); err != nil {
+		return fmt.Errorf("failed to add app '%s' to folder '%s': %w", app.Title, folderName, err)
 	}
 
 	return nil
@@ -494,21 +559,45 @@ func (lp *LaunchPad) FixOther() error {
 		}
 	}
 
+	// The update triggers renumber the pages the apps move into, so they are disabled
+	// and the apps are appended with explicit orderings
+	if err := lp.DisableTriggers(); err != nil {
+		return err
+	}
+	var rootItems int64
+	if err := lp.DB.Model(&Item{}).Where("parent_id = ?", lp.rootPage).Count(&rootItems).Error; err != nil {
+		return fmt.Errorf("failed to count items on root page: %w", err)
+	}
+
 	// move apps to root page
+	var ignored int
 	for _, app := range apps {
+		if lp.ignored[app.ID] { // missing apps policy is 'ignore'
+			ignored++
+			continue
+		}
 		utils.Indent(log.WithField("app", app.Title).Warn, 3)("moving app from Other folder")
-		if cfolder, err := lp.Config.GetFolderContainingApp(app.Title); err == nil {
-			if err := lp.addToFolder(app.Title, cfolder); err != nil { // add to folder it SHOULD have been in
+		if cfolder, err := lp.Config.GetFolderContainingApp(app); err == nil {
+			if err := lp.addToFolder(app, cfolder); err != nil { // add to folder it SHOULD have been in
 				return err
 			}
 		} else {
-			if err := lp.updateItem(app.Title, ApplicationType, lp.rootPage, -1); err != nil { // add to end of root page
+			if err := lp.updateItem(app.Ref(), ApplicationType, lp.rootPage, int(rootItems)); err != nil { // add to end of root page
 				return fmt.Errorf("failed to move app '%s' from Other to root: %w", app.Title, err)
 			}
+			rootItems++
 		}
 
 	}
 
+	if err := lp.EnableTriggers(); err != nil {
+		return err
+	}
+
+	if ignored > 0 { // keep Other for the apps the config leaves alone
+		return nil
+	}
+
 	// remove all traces of Other
 	for _, page := range pages {
 		if err := lp.DB.Delete(&page).Error; err != nil {
@@ -528,34 +617,52 @@ func (lp *LaunchPad) FixOther() error {
 	return nil
 }
 
+// Transaction runs fn inside a single database transaction. If fn returns an
+// error every change made through lp is rolled back, including the update triggers.
+// It refuses to run if the database schema is unknown.
+func (lp *LaunchPad) Transaction(fn func() error) error {
+	if err := lp.CheckWritable(); err != nil {
+		return err
+	}
+	db := lp.DB
+	defer func() { lp.DB = db }()
+	return db.Transaction(func(tx *gorm.DB) error {
+		lp.DB = tx
+		return fn()
+	})
+}
+
+// Close closes the launchpad database connection
+func (lp *LaunchPad) Close() error {
+	db, err := lp.DB.DB()
+	if err != nil {
+		return errors.Wrap(err, "unable to get db when trying to close")
+	}
+	if err := db.Close(); err != nil {
+		return errors.Wrap(err, "unable to close db")
+	}
+	return nil
+}
+
 // EnableTriggers enables item update triggers
 func (lp *LaunchPad) EnableTriggers() error {
 	utils.Indent(log.Info, 2)("enabling SQL update triggers")
-	if err := lp.DB.Exec("UPDATE dbinfo SET value=0 WHERE key='ignore_items_update_triggers';").Error; err != nil {
-		return errors.Wrap(err, "counld not update `ignore_items_update_triggers` to 0")
-	}
-	return nil
+	return lp.setTriggers(true)
 }
 
 // DisableTriggers disables item update triggers
 func (lp *LaunchPad) DisableTriggers() error {
 	utils.Indent(log.Info, 2)("disabling SQL update triggers")
-	if err := lp.DB.Exec("UPDATE dbinfo SET value=1 WHERE key='ignore_items_update_triggers';").Error; err != nil {
-		return errors.Wrap(err, "counld not update `ignore_items_update_triggers` to 1")
-	}
-	return nil
+	return lp.setTriggers(false)
 }
 
 // TriggersDisabled returns true if triggers are disabled
 func (lp *LaunchPad) TriggersDisabled() bool {
-	var dbinfo DBInfo
-	if err := lp.DB.Where("key in (?)", []string{"ignore_items_update_triggers"}).Find(&dbinfo).Error; err != nil {
-		log.WithError(err).Error("dbinfo query failed")
-	}
-	if dbinfo.Value == "1" {
-		return true
+	disabled, err := lp.triggersDisabled()
+	if err != nil {
+		log.WithError(err).Error("unable to read the update triggers setting")
 	}
-	return false
+	return disabled
 }
 
 // GetMaxAppID returns the maximum App ItemID
@@ -0,0 +1,445 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/blacktop/lporg/internal/database/testdb"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// open opens a fixture database as a launchpad
+func open(tb testing.TB, layout testdb.Layout) *LaunchPad {
+	tb.Helper()
+	log.SetLevel(log.ErrorLevel)
+
+	path := testdb.New(tb, layout)
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	lp := &LaunchPad{DB: db, File: path, Folder: filepath.Dir(path)}
+	tb.Cleanup(func() { lp.Close() })
+	return lp
+}
+
+// parse parses a testdb layout description
+func parse(tb testing.TB, data string) testdb.Layout {
+	tb.Helper()
+	layout, err := testdb.Parse([]byte(data))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return layout
+}
+
+// newLaunchPad creates a launchpad database with n generated apps on a single page
+func newLaunchPad(tb testing.TB, n int) *LaunchPad {
+	tb.Helper()
+	return open(tb, testdb.Generate(n, n))
+}
+
+// folderLayout puts the n apps of newLaunchPad into folders of 20 apps, 35 folders per page
+func folderLayout(n int) Apps {
+	var apps Apps
+	for i := 0; i < n; i += 20 {
+		folder := AppFolder{Name: fmt.Sprintf("Folder %d", i/20), Pages: []FolderPage{{Number: 1}}}
+		for j := i; j < i+20 && j < n; j++ {
+			folder.Pages[0].Items = append(folder.Pages[0].Items, AppRef{Bundle: testdb.Bundle(j)})
+		}
+		apps.appendItem(FolderItem(folder), 35)
+	}
+	return apps
+}
+
+// apply lays out the apps the way load does
+func apply(lp *LaunchPad, apps Apps) error {
+	if err := lp.ClearGroups(); err != nil {
+		return err
+	}
+	if err := lp.DisableTriggers(); err != nil {
+		return err
+	}
+	if err := lp.AddRootsAndHoldingPages(); err != nil {
+		return err
+	}
+	if err := lp.ApplyConfig(apps, lp.GetMaxAppID(), 1); err != nil {
+		return err
+	}
+	return lp.EnableTriggers()
+}
+
+func TestLaunchPad_ApplyConfig(t *testing.T) {
+	lp := newLaunchPad(t, 45)
+	if err := apply(lp, folderLayout(45)); err != nil {
+		t.Fatalf("ApplyConfig() error = %v", err)
+	}
+
+	var items []Item
+	if err := lp.DB.Where("type = ?", ApplicationType).Order("rowid").Find(&items).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 45 {
+		t.Fatalf("ApplyConfig() placed %d apps, want 45", len(items))
+	}
+	var groups []Group
+	if err := lp.DB.Where("title IS NOT NULL").Order("item_id").Find(&groups).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 3 {
+		t.Fatalf("ApplyConfig() created %d folders, want 3", len(groups))
+	}
+	for idx, item := range items {
+		var page Item
+		if err := lp.DB.Where("rowid = ?", item.ParentID).First(&page).Error; err != nil {
+			t.Fatalf("app %d has no parent: %v", item.ID, err)
+		}
+		if folder := groups[idx/20]; page.ParentID != folder.ID || item.Ordering != idx%20 {
+			t.Errorf("app %d in folder %d at %d, want folder %d at %d", item.ID, page.ParentID, item.Ordering, folder.ID, idx%20)
+		}
+		if item.UUID != fmt.Sprint("app-", item.ID) {
+			t.Errorf("app %d UUID = %s, want it kept", item.ID, item.UUID)
+		}
+	}
+}
+
+func TestLaunchPad_Transaction_rollback(t *testing.T) {
+	lp := newLaunchPad(t, 45)
+	if err := apply(lp, folderLayout(45)); err != nil {
+		t.Fatalf("ApplyConfig() error = %v", err)
+	}
+
+	snapshot := func() ([]Item, []Group) {
+		var items []Item
+		if err := lp.DB.Order("rowid").Find(&items).Error; err != nil {
+			t.Fatal(err)
+		}
+		var groups []Group
+		if err := lp.DB.Order("item_id").Find(&groups).Error; err != nil {
+			t.Fatal(err)
+		}
+		return items, groups
+	}
+	wantItems, wantGroups := snapshot()
+
+	errApply := errors.New("failed part way through")
+	err := lp.Transaction(func() error {
+		if err := lp.DisableTriggers(); err != nil {
+			return err
+		}
+		if err := lp.ClearGroups(); err != nil {
+			return err
+		}
+		if !lp.TriggersDisabled() {
+			t.Errorf("DisableTriggers() did not disable the triggers inside the transaction")
+		}
+		if items, _ := snapshot(); len(items) >= len(wantItems) {
+			t.Errorf("ClearGroups() did not delete any items inside the transaction")
+		}
+		return errApply
+	})
+	if !errors.Is(err, errApply) {
+		t.Fatalf("Transaction() error = %v, want %v", err, errApply)
+	}
+
+	if lp.TriggersDisabled() {
+		t.Errorf("Transaction() left ignore_items_update_triggers set after rolling back")
+	}
+	items, groups := snapshot()
+	if !reflect.DeepEqual(items, wantItems) {
+		t.Errorf("Transaction() left %d items after rolling back, want the %d before", len(items), len(wantItems))
+	}
+	if !reflect.DeepEqual(groups, wantGroups) {
+		t.Errorf("Transaction() left groups %v after rolling back, want %v", groups, wantGroups)
+	}
+}
+
+func TestLaunchPad_saveItems(t *testing.T) {
+	tests := []struct {
+		name    string
+		padding int // other updates between the two updates of the same item
+	}{
+		{name: "same batch"},
+		{name: "next batch", padding: itemBatchSize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lp := newLaunchPad(t, 3)
+			var items []Item
+			if err := lp.DB.Where("type = ?", ApplicationType).Order("rowid").Find(&items).Error; err != nil {
+				t.Fatal(err)
+			}
+			if len(items) < 2 {
+				t.Fatalf("found %d app items, want at least 2", len(items))
+			}
+			if err := lp.DisableTriggers(); err != nil {
+				t.Fatal(err)
+			}
+
+			first, last := items[0], items[0]
+			first.Ordering, last.Ordering = 100, 200
+			updates := []Item{first}
+			for idx := 0; idx < tt.padding; idx++ {
+				updates = append(updates, items[1])
+			}
+			updates = append(updates, last)
+			if err := lp.saveItems(updates); err != nil {
+				t.Fatalf("saveItems() error = %v", err)
+			}
+
+			var got Item
+			if err := lp.DB.Where("rowid = ?", first.ID).First(&got).Error; err != nil {
+				t.Fatal(err)
+			}
+			if got.Ordering != last.Ordering {
+				t.Errorf("saveItems() ordering = %d, want the last update %d", got.Ordering, last.Ordering)
+			}
+		})
+	}
+}
+
+func TestLaunchPad_ReadLayout(t *testing.T) {
+	lp := newLaunchPad(t, 41)
+	apps := folderLayout(40)
+	apps.appendItem(AppItem(AppRef{Title: testdb.Title(40)}), 35)
+	if err := apply(lp, apps); err != nil {
+		t.Fatalf("ApplyConfig() error = %v", err)
+	}
+
+	layout, err := lp.ReadLayout()
+	if err != nil {
+		t.Fatalf("ReadLayout() error = %v", err)
+	}
+	if len(layout.Launchpad) != 1 || len(layout.Dashboard) != 0 {
+		t.Fatalf("ReadLayout() = %d launchpad and %d dashboard pages, want 1 and 0", len(layout.Launchpad), len(layout.Dashboard))
+	}
+	items := layout.Launchpad[0].Items
+	if len(items) != 3 {
+		t.Fatalf("ReadLayout() page 1 has %d items, want 3", len(items))
+	}
+	for idx, item := range items[:2] {
+		if item.Type != FolderRootType || item.Group.Title != fmt.Sprintf("Folder %d", idx) {
+			t.Errorf("ReadLayout() item %d = %d '%s', want folder 'Folder %d'", idx, item.Type, item.Group.Title, idx)
+		}
+		if len(item.Pages) != 1 || len(item.Pages[0].Apps) != 20 {
+			t.Fatalf("ReadLayout() folder %d pages = %v, want 1 page of 20 apps", idx, item.Pages)
+		}
+		if got, want := item.Pages[0].Apps[3].App.BundleID, testdb.Bundle(idx*20+3); got != want {
+			t.Errorf("ReadLayout() folder %d app 4 = %s, want %s", idx, got, want)
+		}
+	}
+	if items[2].Type != ApplicationType || items[2].App.Title != testdb.Title(40) {
+		t.Errorf("ReadLayout() item 3 = %d '%s', want app '%s'", items[2].Type, items[2].App.Title, testdb.Title(40))
+	}
+}
+
+func TestLaunchPad_Reconcile(t *testing.T) {
+	lp := newLaunchPad(t, 45)
+	if err := apply(lp, folderLayout(45)); err != nil {
+		t.Fatalf("ApplyConfig() error = %v", err)
+	}
+	before, err := lp.ReadLayout()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// swap two apps between folders, drop the last folder and add a new one
+	apps := folderLayout(40)
+	first, second := apps.Pages[0].Items[0].Folder, apps.Pages[0].Items[1].Folder
+	first.Pages[0].Items[0], second.Pages[0].Items[0] = second.Pages[0].Items[0], first.Pages[0].Items[0]
+	apps.appendItem(FolderItem(AppFolder{Name: "New", Pages: []FolderPage{{Number: 1, Items: []AppRef{{Title: testdb.Title(44)}}}}}), 35)
+
+	if err := lp.DisableTriggers(); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := lp.Reconcile(apps)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if err := lp.EnableTriggers(); err != nil {
+		t.Fatal(err)
+	}
+	if want := (ReconcileStats{Kept: 5, Created: 2, Deleted: 2, Moved: 3}); *stats != want {
+		t.Errorf("Reconcile() = %+v, want %+v", *stats, want)
+	}
+
+	after, err := lp.ReadLayout()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Launchpad[0].UUID != before.Launchpad[0].UUID {
+		t.Errorf("Reconcile() page UUID = %s, want %s", after.Launchpad[0].UUID, before.Launchpad[0].UUID)
+	}
+	items := after.Launchpad[0].Items
+	if len(items) != 3 {
+		t.Fatalf("Reconcile() page 1 has %d items, want 3", len(items))
+	}
+	for idx, item := range items[:2] {
+		if prev := before.Launchpad[0].Items[idx]; item.ID != prev.ID || item.UUID != prev.UUID || item.Pages[0].ID != prev.Pages[0].ID {
+			t.Errorf("Reconcile() folder %d = %d %s, want %d %s kept", idx, item.ID, item.UUID, prev.ID, prev.UUID)
+		}
+	}
+	if got := items[0].Pages[0].Apps[0].App.BundleID; got != testdb.Bundle(20) {
+		t.Errorf("Reconcile() folder 0 app 1 = %s, want %s", got, testdb.Bundle(20))
+	}
+	if got := items[2].Group.Title; got != "New" || len(items[2].Pages) != 1 || len(items[2].Pages[0].Apps) != 1 {
+		t.Errorf("Reconcile() item 3 = folder '%s' %v, want folder 'New' with 1 app", got, items[2].Pages)
+	}
+	for _, app := range items[0].Pages[0].Apps {
+		if app.Ordering < 0 || app.ParentID != items[0].Pages[0].ID {
+			t.Errorf("Reconcile() app %d has parent %d at %d", app.ID, app.ParentID, app.Ordering)
+		}
+	}
+
+	var groups int64
+	if err := lp.DB.Model(&Group{}).Where("title = ?", "Folder 2").Count(&groups).Error; err != nil {
+		t.Fatal(err)
+	}
+	if groups != 0 {
+		t.Errorf("Reconcile() kept %d 'Folder 2' groups, want it deleted", groups)
+	}
+}
+
+func TestLaunchPad_GetMissing(t *testing.T) {
+	lp := open(t, parse(t, `
+pages:
+  - - Safari
+    - {title: Mail, bundle: com.apple.mail}
+    - folder: Developer
+      pages:
+        - [Xcode, Terminal]
+    - {title: Chess, category: public.app-category.games}
+`))
+	lp.Config.Grid = Grid{}.WithDefaults(Grid{})
+	lp.Config.Rules = []Rule{{Folder: "Games", Category: "public.app-category.games"}}
+
+	config := Apps{Pages: []Page{{Number: 1, Items: []PageItem{
+		AppItem(AppRef{Title: "Safari"}),
+		AppItem(AppRef{Title: "Old Mail", Bundle: "com.apple.mail"}),
+		FolderItem(AppFolder{Name: "Developer", Pages: []FolderPage{{Number: 1, Items: []AppRef{{Title: "Xcode"}, {Title: "Photoshop"}}}}}),
+		FolderItem(AppFolder{Name: "Video", Pages: []FolderPage{{Number: 1, Items: []AppRef{{Title: "Final Cut Pro"}}}}}),
+	}}}}
+
+	got, report, err := lp.GetMissing(config, ApplicationType)
+	if err != nil {
+		t.Fatalf("GetMissing() error = %v", err)
+	}
+
+	want := Apps{Pages: []Page{{Number: 1, Items: []PageItem{
+		AppItem(AppRef{Title: "Safari"}),
+		AppItem(AppRef{Title: "Old Mail", Bundle: "com.apple.mail"}),
+		FolderItem(AppFolder{Name: "Developer", Pages: []FolderPage{{Number: 1, Items: []AppRef{{Title: "Xcode"}}}}}),
+		FolderItem(AppFolder{Name: "Games", Pages: []FolderPage{{Number: 1, Items: []AppRef{{Title: "Chess"}}}}}),
+		AppItem(AppRef{Title: "Terminal"}),
+	}}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetMissing() = %v, want %v", got.Pages, want.Pages)
+	}
+	wantReport := MissingReport{
+		Unlisted:     []string{"Chess", "Terminal"},
+		Uninstalled:  []string{"Photoshop", "Final Cut Pro"},
+		EmptyFolders: []string{"Video"},
+	}
+	if !reflect.DeepEqual(*report, wantReport) {
+		t.Errorf("GetMissing() report = %+v, want %+v", *report, wantReport)
+	}
+}
+
+func TestLaunchPad_FixOther(t *testing.T) {
+	lp := open(t, parse(t, `
+pages:
+  - - Safari
+    - folder: Developer
+      pages:
+        - [Xcode]
+  - - folder: Other
+      pages:
+        - [Terminal, Chess]
+`))
+	layout, err := lp.ReadLayout()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lp.rootPage = layout.Launchpad[0].ID
+	lp.Config.Apps = Apps{Pages: []Page{{Number: 1, Items: []PageItem{
+		AppItem(AppRef{Title: "Safari"}),
+		FolderItem(AppFolder{Name: "Developer", Pages: []FolderPage{{Number: 1, Items: []AppRef{{Title: "Xcode"}, {Title: "Terminal"}}}}}),
+	}}}}
+
+	if err := lp.FixOther(); err != nil {
+		t.Fatalf("FixOther() error = %v", err)
+	}
+
+	if layout, err = lp.ReadLayout(); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, item := range layout.Launchpad[0].Items {
+		if item.Type == FolderRootType {
+			for _, app := range item.Pages[0].Apps {
+				got = append(got, item.Group.Title+"/"+app.App.Title)
+			}
+			continue
+		}
+		got = append(got, item.App.Title)
+	}
+	if want := []string{"Safari", "Developer/Xcode", "Developer/Terminal", "Chess"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FixOther() page 1 = %v, want %v", got, want)
+	}
+	if len(layout.Launchpad[1].Items) != 0 {
+		t.Errorf("FixOther() left %d items on page 2, want the Other folder removed", len(layout.Launchpad[1].Items))
+	}
+	var other int64
+	if err := lp.DB.Model(&Group{}).Where("title = ?", "Other").Count(&other).Error; err != nil {
+		t.Fatal(err)
+	}
+	if other != 0 {
+		t.Errorf("FixOther() kept the Other group")
+	}
+}
+
+// rollback is returned from benchmark transactions so every iteration starts from the same database
+var rollback = fmt.Errorf("rollback")
+
+func BenchmarkLaunchPad_ApplyConfig(b *testing.B) {
+	lp := newLaunchPad(b, 600)
+	apps := folderLayout(600)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := lp.Transaction(func() error {
+			if err := apply(lp, apps); err != nil {
+				return err
+			}
+			return rollback
+		}); err != rollback {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLaunchPad_FlattenApps(b *testing.B) {
+	lp := newLaunchPad(b, 600)
+	layout, err := lp.ReadLayout()
+	if err != nil {
+		b.Fatal(err)
+	}
+	lp.rootPage = layout.Launchpad[0].ID
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := lp.Transaction(func() error {
+			if err := lp.FlattenApps(); err != nil {
+				return err
+			}
+			return rollback
+		}); err != rollback {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package database
+
+import "errors"
+
+var (
+	// ErrDBNotFound is returned when the Launchpad database does not exist
+	ErrDBNotFound = errors.New("launchpad database not found")
+	// ErrDBNotReady is returned when the Dock does not rebuild the Launchpad database in time
+	ErrDBNotReady = errors.New("launchpad database not ready")
+	// ErrConfigNotFound is returned when the config file does not exist or cannot be read
+	ErrConfigNotFound = errors.New("config file not found")
+	// ErrConfigInvalid is returned when the config file cannot be parsed or fails verification
+	ErrConfigInvalid = errors.New("invalid config")
+	// ErrAppNotInstalled is returned with --strict when the config lists apps that are not installed
+	ErrAppNotInstalled = errors.New("app not installed")
+)
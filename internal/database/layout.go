@@ -0,0 +1,123 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// reservedUUIDs are the root and holding page items that are not part of the visible layout
+var reservedUUIDs = []string{"ROOTPAGE", "HOLDINGPAGE", "ROOTPAGE_DB", "HOLDINGPAGE_DB", "ROOTPAGE_VERS", "HOLDINGPAGE_VERS"}
+
+// Layout is the current launchpad and dashboard layout in the database
+type Layout struct {
+	LaunchpadRoot int
+	DashboardRoot int
+	Launchpad     []LayoutPage
+	Dashboard     []LayoutPage
+}
+
+// LayoutPage is a launchpad page and the apps and folders on it in order
+type LayoutPage struct {
+	Item
+	Items []LayoutItem
+}
+
+// LayoutItem is an app or a folder on a launchpad page.
+// Apps have Item.App set, folders have Item.Group set and their pages.
+type LayoutItem struct {
+	Item
+	Pages []LayoutFolderPage
+}
+
+// LayoutFolderPage is a folder page and the apps on it in order
+type LayoutFolderPage struct {
+	Item
+	Apps []Item
+}
+
+// layoutRow is an item joined with its app and group
+type layoutRow struct {
+	ID         int
+	UUID       string
+	Flags      sql.NullInt64
+	Type       int
+	ParentID   int
+	Ordering   int
+	AppTitle   sql.NullString
+	BundleID   sql.NullString
+	CategoryID sql.NullInt64
+	GroupTitle sql.NullString
+}
+
+func (r layoutRow) item() Item {
+	item := Item{
+		ID:       r.ID,
+		UUID:     r.UUID,
+		Flags:    int(r.Flags.Int64),
+		Type:     r.Type,
+		ParentID: r.ParentID,
+		Ordering: r.Ordering,
+	}
+	if r.AppTitle.Valid || r.BundleID.Valid {
+		item.App = App{ID: r.ID, Title: r.AppTitle.String, BundleID: r.BundleID.String, CategoryID: int(r.CategoryID.Int64)}
+	}
+	if r.GroupTitle.Valid {
+		item.Group = Group{ID: r.ID, Title: r.GroupTitle.String}
+	}
+	return item
+}
+
+// ReadLayout reads every item with its app and group in a single query and
+// returns the launchpad and dashboard trees of pages, folders and apps
+func (lp *LaunchPad) ReadLayout() (*Layout, error) {
+	var rows []layoutRow
+
+	launchpadRoot, dashboardRoot, err := lp.roots()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lp.DB.Table("items").
+		Select("items.rowid AS id, items.uuid, items.flags, items.type, items.parent_id, items.ordering, "+
+			"apps.title AS app_title, apps.bundleid AS bundle_id, apps.category_id, groups.title AS group_title").
+		Joins("LEFT JOIN apps ON apps.item_id = items.rowid").
+		Joins("LEFT JOIN groups ON groups.item_id = items.rowid").
+		Where("items.uuid NOT IN (?)", reservedUUIDs).
+		Order("items.parent_id, items.ordering").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("items query failed: %w", err)
+	}
+
+	children := make(map[int][]Item)
+	for _, row := range rows {
+		children[row.ParentID] = append(children[row.ParentID], row.item())
+	}
+
+	pages := func(root int) []LayoutPage {
+		var pages []LayoutPage
+		if root == 0 { // no dashboard
+			return nil
+		}
+		for _, page := range children[root] {
+			lpage := LayoutPage{Item: page}
+			for _, item := range children[page.ID] {
+				litem := LayoutItem{Item: item}
+				if item.Type == FolderRootType {
+					for _, fpage := range children[item.ID] {
+						litem.Pages = append(litem.Pages, LayoutFolderPage{Item: fpage, Apps: children[fpage.ID]})
+					}
+				}
+				lpage.Items = append(lpage.Items, litem)
+			}
+			pages = append(pages, lpage)
+		}
+		return pages
+	}
+
+	return &Layout{
+		LaunchpadRoot: launchpadRoot,
+		DashboardRoot: dashboardRoot,
+		Launchpad:     pages(launchpadRoot),
+		Dashboard:     pages(dashboardRoot),
+	}, nil
+}
@@ -20,11 +20,15 @@ type LaunchPad struct {
 	Folder string
 
 	Config Config
+	// Scratch marks a throwaway copy of the database, e.g. a dry run snapshot. Unknown
+	// schemas are written to it the way v1 is, as far as they allow.
+	Scratch bool
 
+	schema      *Schema
 	rootPage    int
-	dbApps      []string
-	confApps    []string
+	dbApps      []App
 	confFolders []string
+	ignored     map[int]bool
 }
 
 // App CREATE TABLE apps (item_id INTEGER PRIMARY KEY, title VARCHAR, bundleid VARCHAR, storeid VARCHAR,category_id INTEGER, moddate REAL, bookmark BLOB)
@@ -39,6 +43,11 @@ type App struct {
 	Bookmark   []byte  `gorm:"column:bookmark"`
 }
 
+// Ref returns a config reference to the app
+func (a App) Ref() AppRef {
+	return AppRef{Title: a.Title, Bundle: a.BundleID}
+}
+
 // Category CREATE TABLE categories (rowid INTEGER PRIMARY KEY ASC, uti VARCHAR)
 type Category struct {
 	ID  uint   `gorm:"column:rowid;primary_key"`
@@ -0,0 +1,186 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Placement policies for installed apps that are not in the config
+const (
+	PlaceAppend             = "append"
+	PlaceNewPage            = "new-page"
+	PlaceFolder             = "folder"
+	PlaceAlphabeticalInsert = "alphabetical-insert"
+	PlaceIgnore             = "ignore"
+)
+
+// Placement is the policy for placing installed apps that are not in the config
+type Placement struct {
+	Policy string
+	Folder string
+}
+
+// ParsePlacement parses a placement policy such as 'append' or 'folder:Unsorted'
+func ParsePlacement(s string) (Placement, error) {
+	policy, folder, _ := strings.Cut(s, ":")
+	p := Placement{Policy: strings.TrimSpace(policy), Folder: strings.TrimSpace(folder)}
+	switch p.Policy {
+	case "":
+		p.Policy = PlaceAppend
+	case PlaceFolder:
+		if len(p.Folder) == 0 {
+			return p, fmt.Errorf("placement policy '%s' requires a folder name (e.g. 'folder:Unsorted')", s)
+		}
+		return p, nil
+	case PlaceAppend, PlaceNewPage, PlaceAlphabeticalInsert, PlaceIgnore:
+	default:
+		return p, fmt.Errorf("unknown placement policy '%s' (must be one of: %s, %s, %s:<name>, %s, %s)",
+			s, PlaceAppend, PlaceNewPage, PlaceFolder, PlaceAlphabeticalInsert, PlaceIgnore)
+	}
+	if len(p.Folder) > 0 {
+		return p, fmt.Errorf("placement policy '%s' does not take a folder name", p.Policy)
+	}
+	return p, nil
+}
+
+func (p Placement) String() string {
+	if p.Policy == PlaceFolder {
+		return p.Policy + ":" + p.Folder
+	}
+	if len(p.Policy) == 0 {
+		return PlaceAppend
+	}
+	return p.Policy
+}
+
+// UnmarshalYAML decodes a policy name or a {folder: <name>} mapping
+func (p *Placement) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.MappingNode {
+		var f struct {
+			Folder string `yaml:"folder"`
+		}
+		if err := value.Decode(&f); err != nil {
+			return errors.Wrap(err, "unable to decode placement policy")
+		}
+		value = &yaml.Node{Kind: yaml.ScalarNode, Value: PlaceFolder + ":" + f.Folder}
+	}
+	parsed, err := ParsePlacement(value.Value)
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+// MarshalYAML encodes the policy name or a {folder: <name>} mapping
+func (p Placement) MarshalYAML() (any, error) {
+	if p.Policy == PlaceFolder {
+		return map[string]string{"folder": p.Folder}, nil
+	}
+	return p.String(), nil
+}
+
+// UnmarshalJSON decodes a policy name or a {"folder": "<name>"} object
+func (p *Placement) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		var f struct {
+			Folder string `json:"folder"`
+		}
+		if err := json.Unmarshal(data, &f); err != nil {
+			return errors.Wrap(err, "unable to decode placement policy")
+		}
+		name = PlaceFolder + ":" + f.Folder
+	}
+	parsed, err := ParsePlacement(name)
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+// MarshalJSON encodes the policy name or a {"folder": "<name>"} object
+func (p Placement) MarshalJSON() ([]byte, error) {
+	if p.Policy == PlaceFolder {
+		return json.Marshal(map[string]string{"folder": p.Folder})
+	}
+	return json.Marshal(p.String())
+}
+
+// IsZero reports whether no policy was set
+func (p Placement) IsZero() bool {
+	return len(p.Policy) == 0
+}
+
+// place adds the installed apps that are not in the config according to the policy
+func (a *Apps) place(p Placement, unplaced []App, grid Grid) error {
+	if len(unplaced) == 0 {
+		return nil
+	}
+	switch p.Policy {
+	case "", PlaceAppend:
+		for _, app := range unplaced {
+			a.appendItem(AppItem(app.Ref()), grid.PageCapacity())
+		}
+	case PlaceNewPage:
+		a.Pages = append(a.Pages, Page{Number: len(a.Pages) + 1})
+		for _, app := range unplaced {
+			a.appendItem(AppItem(app.Ref()), grid.PageCapacity())
+		}
+	case PlaceFolder:
+		for _, app := range unplaced {
+			if _, err := a.addToFolder(p.Folder, app.Ref(), grid); err != nil {
+				return err
+			}
+		}
+	case PlaceAlphabeticalInsert:
+		for _, app := range unplaced {
+			if err := a.insertAlphabetically(app.Ref(), grid.PageCapacity()); err != nil {
+				return err
+			}
+		}
+	case PlaceIgnore:
+	default:
+		return fmt.Errorf("unknown placement policy '%s'", p.Policy)
+	}
+	return nil
+}
+
+// insertAlphabetically inserts the app before the first page item (app title or folder name)
+// that sorts after it. Full pages push their last item onto the next page.
+func (a *Apps) insertAlphabetically(app AppRef, capacity int) error {
+	title := strings.ToLower(app.String())
+	pidx, iidx := len(a.Pages)-1, -1
+search:
+	for p, page := range a.Pages {
+		for i, item := range page.Items {
+			if strings.ToLower(item.Name()) > title {
+				pidx, iidx = p, i
+				break search
+			}
+		}
+	}
+	if pidx < 0 {
+		a.appendItem(AppItem(app), capacity)
+		return nil
+	}
+	if iidx < 0 {
+		iidx = len(a.Pages[pidx].Items)
+	}
+	a.Pages[pidx].Items = append(a.Pages[pidx].Items[:iidx], append([]PageItem{AppItem(app)}, a.Pages[pidx].Items[iidx:]...)...)
+	// cascade overflow onto the following pages
+	for p := pidx; p < len(a.Pages) && len(a.Pages[p].Items) > capacity; p++ {
+		last := a.Pages[p].Items[len(a.Pages[p].Items)-1]
+		a.Pages[p].Items = a.Pages[p].Items[:len(a.Pages[p].Items)-1]
+		if p == len(a.Pages)-1 {
+			a.Pages = append(a.Pages, Page{Number: len(a.Pages) + 1})
+		}
+		a.Pages[p+1].Items = append([]PageItem{last}, a.Pages[p+1].Items...)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApps_place(t *testing.T) {
+	unplaced := []App{{Title: "Chess"}, {Title: "Notes"}}
+	grid := Grid{Rows: 1, Columns: 3, FolderRows: 1, FolderColumns: 3}
+	tests := []struct {
+		name   string
+		policy string
+		want   []Page
+	}{
+		{
+			name:   "append",
+			policy: "append",
+			want: []Page{
+				{Number: 1, Items: []PageItem{app("Mail"), app("Xcode"), app("Chess")}},
+				{Number: 2, Items: []PageItem{app("Notes")}},
+			},
+		},
+		{
+			name:   "new page",
+			policy: "new-page",
+			want: []Page{
+				{Number: 1, Items: []PageItem{app("Mail"), app("Xcode")}},
+				{Number: 2, Items: []PageItem{app("Chess"), app("Notes")}},
+			},
+		},
+		{
+			name:   "folder",
+			policy: "folder:Unsorted",
+			want: []Page{
+				{Number: 1, Items: []PageItem{app("Mail"), app("Xcode"), FolderItem(AppFolder{
+					Name:  "Unsorted",
+					Pages: []FolderPage{{Number: 1, Items: []AppRef{{Title: "Chess"}, {Title: "Notes"}}}},
+				})}},
+			},
+		},
+		{
+			name:   "alphabetical insert",
+			policy: "alphabetical-insert",
+			want: []Page{
+				{Number: 1, Items: []PageItem{app("Chess"), app("Mail"), app("Notes")}},
+				{Number: 2, Items: []PageItem{app("Xcode")}},
+			},
+		},
+		{
+			name:   "ignore",
+			policy: "ignore",
+			want:   []Page{{Number: 1, Items: []PageItem{app("Mail"), app("Xcode")}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ParsePlacement(tt.policy)
+			if err != nil {
+				t.Fatalf("ParsePlacement() error = %v", err)
+			}
+			apps := Apps{Pages: []Page{{Number: 1, Items: []PageItem{app("Mail"), app("Xcode")}}}}
+			if err := apps.place(p, unplaced, grid); err != nil {
+				t.Fatalf("Apps.place() error = %v", err)
+			}
+			if !reflect.DeepEqual(apps.Pages, tt.want) {
+				t.Errorf("Apps.place() = %v, want %v", apps.Pages, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// readyPollInterval is how often WaitReady checks the database
+var readyPollInterval = 250 * time.Millisecond
+
+// uriEscaper escapes the characters SQLite gives a meaning in file: URIs
+var uriEscaper = strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23")
+
+// WaitReady waits until the Dock has rebuilt the database in file after a restart: it
+// exists, has its launchpad root in dbinfo and lists apps. It polls without creating or
+// writing the file and fails with ErrDBNotReady and the last problem found after timeout.
+func WaitReady(ctx context.Context, file string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		err := checkReady(file)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("%w after %s: %v", ErrDBNotReady, timeout, err)
+			}
+			return ctx.Err()
+		case <-time.After(readyPollInterval):
+		}
+	}
+}
+
+// checkReady returns why the database in file is not ready or nil if it is
+func checkReady(file string) error {
+	if _, err := os.Stat(file); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%s does not exist yet", file)
+		}
+		return err
+	}
+
+	dsn := fmt.Sprintf("file:%s?mode=ro&_pragma=busy_timeout(%d)", uriEscaper.Replace(file), readyPollInterval.Milliseconds())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return err
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+
+	var roots int64
+	if err := db.Model(&DBInfo{}).Where("key = ?", "launchpad_root").Count(&roots).Error; err != nil {
+		return err
+	}
+	if roots == 0 {
+		return fmt.Errorf("no launchpad root in dbinfo yet")
+	}
+	var apps int64
+	if err := db.Table("apps").Count(&apps).Error; err != nil {
+		return err
+	}
+	if apps == 0 {
+		return fmt.Errorf("no apps yet")
+	}
+	return nil
+}
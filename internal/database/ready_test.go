@@ -0,0 +1,104 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blacktop/lporg/internal/database/testdb"
+)
+
+func TestWaitReady(t *testing.T) {
+	orig := readyPollInterval
+	t.Cleanup(func() { readyPollInterval = orig })
+	readyPollInterval = 10 * time.Millisecond
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, path string) // prepares the database at path, nil for none
+		after   time.Duration                   // how long after the wait starts setup runs
+		ctx     context.Context
+		wantErr error
+		wantMsg string
+	}{
+		{
+			name:  "ready",
+			setup: func(t *testing.T, path string) { create(t, path, testdb.Generate(3, 35)) },
+		},
+		{
+			name:  "rebuilt while waiting",
+			setup: func(t *testing.T, path string) { create(t, path, testdb.Generate(3, 35)) },
+			after: 50 * time.Millisecond,
+		},
+		{
+			name:    "missing",
+			wantErr: ErrDBNotReady,
+			wantMsg: "does not exist yet",
+		},
+		{
+			name: "empty file",
+			setup: func(t *testing.T, path string) {
+				if err := os.WriteFile(path, nil, 0644); err != nil {
+					t.Error(err)
+				}
+			},
+			wantErr: ErrDBNotReady,
+			wantMsg: "no such table: dbinfo",
+		},
+		{
+			name:    "no apps",
+			setup:   func(t *testing.T, path string) { create(t, path, testdb.Layout{}) },
+			wantErr: ErrDBNotReady,
+			wantMsg: "no apps yet",
+		},
+		{
+			name:    "interrupted",
+			ctx:     cancelled,
+			wantErr: context.Canceled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "db")
+			if tt.setup != nil {
+				if tt.after > 0 {
+					timer := time.AfterFunc(tt.after, func() { tt.setup(t, path) })
+					defer timer.Stop()
+				} else {
+					tt.setup(t, path)
+				}
+			}
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			err := WaitReady(ctx, path, time.Second)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("WaitReady() error = %v", err)
+				}
+			} else if !errors.Is(err, tt.wantErr) || !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Fatalf("WaitReady() error = %v, want %v: %s", err, tt.wantErr, tt.wantMsg)
+			}
+			if tt.setup == nil {
+				if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("WaitReady() created the database: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func create(t *testing.T, path string, layout testdb.Layout) {
+	if err := testdb.Create(path, layout); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,186 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/apex/log"
+	"github.com/blacktop/lporg/internal/utils"
+)
+
+// ReconcileStats counts the changes Reconcile made to the layout
+type ReconcileStats struct {
+	Kept    int `json:"kept"`    // pages, folders and folder pages reused in place
+	Created int `json:"created"` // pages, folders and folder pages created
+	Deleted int `json:"deleted"` // pages, folders and folder pages no longer in the config
+	Moved   int `json:"moved"`   // items whose parent or position changed
+}
+
+func (s ReconcileStats) String() string {
+	return fmt.Sprintf("kept %d, created %d and deleted %d pages and folders, moved %d items", s.Kept, s.Created, s.Deleted, s.Moved)
+}
+
+// Reconcile updates the launchpad layout in place to match config. Existing pages are
+// reused by position and folders by name so their row IDs and UUIDs stay the same; only
+// the missing pages and folders are created, the unused ones deleted and the items whose
+// parent or position changed are written.
+func (lp *LaunchPad) Reconcile(config Apps) (*ReconcileStats, error) {
+	var stats ReconcileStats
+
+	layout, err := lp.ReadLayout()
+	if err != nil {
+		return nil, err
+	}
+	index, err := lp.loadAppIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	var maxID int
+	if err := lp.DB.Table("items").Select("IFNULL(MAX(rowid), 0)").Scan(&maxID).Error; err != nil {
+		return nil, fmt.Errorf("failed to query max item ID: %w", err)
+	}
+	nextID := func() int {
+		maxID++
+		return maxID
+	}
+
+	// existing folders in layout order, so duplicates are reused first come first served
+	folders := make(map[string][]LayoutItem)
+	for _, page := range layout.Launchpad {
+		for _, item := range page.Items {
+			if item.Type == FolderRootType {
+				folders[item.Group.Title] = append(folders[item.Group.Title], item)
+			}
+		}
+	}
+
+	used := make(map[int]bool)
+	var moved []Item
+	move := func(item Item, itemType, parentID, ordering int) {
+		if item.Type != itemType || item.ParentID != parentID || item.Ordering != ordering {
+			item.Type, item.ParentID, item.Ordering = itemType, parentID, ordering
+			moved = append(moved, item)
+		}
+	}
+	place := func(ref AppRef, parentID, ordering int) error {
+		item, err := index.find(ref)
+		if err != nil {
+			return err
+		}
+		move(item, ApplicationType, parentID, ordering)
+		return nil
+	}
+
+	for pidx, page := range config.Pages {
+		var pageID int
+		if pidx < len(layout.Launchpad) {
+			existing := layout.Launchpad[pidx].Item
+			pageID = existing.ID
+			move(existing, PageType, layout.LaunchpadRoot, page.Number)
+			used[pageID] = true
+			stats.Kept++
+		} else {
+			pageID = nextID()
+			if err := lp.createNewPage(pageID, layout.LaunchpadRoot, page.Number); err != nil {
+				return nil, err
+			}
+			stats.Created++
+		}
+
+		if page.Number == 1 {
+			lp.rootPage = pageID
+		}
+
+		for idx, item := range page.Items {
+			switch {
+			case item.App != nil:
+				if err := place(*item.App, pageID, idx); err != nil {
+					return nil, fmt.Errorf("failed to place app on page %d: %w", page.Number, err)
+				}
+			case item.Folder != nil:
+				folder := item.Folder
+
+				var (
+					folderID int
+					fpages   []LayoutFolderPage
+				)
+				if existing := folders[folder.Name]; len(existing) > 0 {
+					folders[folder.Name] = existing[1:]
+					folderID, fpages = existing[0].ID, existing[0].Pages
+					move(existing[0].Item, FolderRootType, pageID, idx)
+					used[folderID] = true
+					stats.Kept++
+				} else {
+					folderID = nextID()
+					if err := lp.createNewFolder(folder.Name, folderID, pageID, idx); err != nil {
+						return nil, err
+					}
+					stats.Created++
+				}
+
+				for fidx, fpage := range folder.Pages {
+					var fpageID int
+					if fidx < len(fpages) {
+						fpageID = fpages[fidx].ID
+						move(fpages[fidx].Item, PageType, folderID, fpage.Number)
+						used[fpageID] = true
+						stats.Kept++
+					} else {
+						fpageID = nextID()
+						if err := lp.createNewFolderPage(fpageID, folderID, fpage.Number); err != nil {
+							return nil, err
+						}
+						stats.Created++
+					}
+
+					for aidx, ref := range fpage.Items {
+						if err := place(ref, fpageID, aidx); err != nil {
+							return nil, fmt.Errorf("failed to place app in folder '%s': %w", folder.Name, err)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	// The item_deleted trigger always shifts the siblings after a deleted item, so unused
+	// pages and folders are first detached from the layout and then deleted
+	var unused []Item
+	var unusedIDs []int
+	detach := func(item Item) {
+		if !used[item.ID] {
+			utils.Indent(log.WithField("id", item.ID).Debug, 3)("removing unused item")
+			item.ParentID = -1
+			unused = append(unused, item)
+			unusedIDs = append(unusedIDs, item.ID)
+		}
+	}
+	for _, page := range layout.Launchpad {
+		detach(page.Item)
+		for _, item := range page.Items {
+			if item.Type != FolderRootType {
+				continue
+			}
+			detach(item.Item)
+			for _, fpage := range item.Pages {
+				detach(fpage.Item)
+			}
+		}
+	}
+	if len(unused) > 0 {
+		if err := lp.saveItems(unused); err != nil {
+			return nil, err
+		}
+		if err := lp.DB.Where("rowid IN (?)", unusedIDs).Delete(&Item{}).Error; err != nil {
+			return nil, fmt.Errorf("failed to delete %d unused items: %w", len(unusedIDs), err)
+		}
+		stats.Deleted = len(unused)
+	}
+
+	if err := lp.saveItems(moved); err != nil {
+		return nil, err
+	}
+	stats.Moved = len(moved)
+
+	return &stats, nil
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Rule routes installed apps that are not in the config into a folder.
+// All of the set criteria must match; a rule without criteria matches every app.
+type Rule struct {
+	Folder       string `yaml:"folder" json:"folder"`
+	Category     string `yaml:"category,omitempty" json:"category,omitempty"`
+	BundlePrefix string `yaml:"bundle_prefix,omitempty" json:"bundle_prefix,omitempty"`
+	Title        string `yaml:"title,omitempty" json:"title,omitempty"`
+
+	title *regexp.Regexp
+}
+
+// compile validates the rule and compiles its title regex
+func (r *Rule) compile() error {
+	if len(r.Folder) == 0 {
+		return fmt.Errorf("rule must have a folder")
+	}
+	if len(r.Title) > 0 {
+		re, err := regexp.Compile(r.Title)
+		if err != nil {
+			return fmt.Errorf("rule for folder '%s' has invalid title regex: %w", r.Folder, err)
+		}
+		r.title = re
+	}
+	return nil
+}
+
+// Match returns true if the app matches all of the rule's criteria
+func (r Rule) Match(app App, categoryUTI string) bool {
+	if len(r.Category) > 0 && r.Category != categoryUTI {
+		return false
+	}
+	if len(r.BundlePrefix) > 0 && !strings.HasPrefix(app.BundleID, r.BundlePrefix) {
+		return false
+	}
+	if r.title != nil && !r.title.MatchString(app.Title) {
+		return false
+	}
+	return true
+}
+
+// route returns the folder of the first rule the app matches
+func route(rules []Rule, app App, categoryUTI string) (string, bool) {
+	for _, rule := range rules {
+		if rule.Match(app, categoryUTI) {
+			return rule.Folder, true
+		}
+	}
+	return "", false
+}
+
+// appendItem adds the item to the last page, starting a new page when it is full
+func (a *Apps) appendItem(item PageItem, capacity int) {
+	if len(a.Pages) == 0 || len(a.Pages[len(a.Pages)-1].Items) >= capacity {
+		a.Pages = append(a.Pages, Page{Number: len(a.Pages) + 1})
+	}
+	a.Pages[len(a.Pages)-1].Items = append(a.Pages[len(a.Pages)-1].Items, item)
+}
+
+// addToFolder adds the app to the last page of the named folder,
+// creating the folder at the end of the layout if it does not exist yet
+func (a *Apps) addToFolder(name string, app AppRef, grid Grid) (created bool, err error) {
+	for _, page := range a.Pages {
+		for _, item := range page.Items {
+			folder := item.Folder
+			if folder == nil || folder.Name != name {
+				continue
+			}
+			if len(folder.Pages) == 0 || len(folder.Pages[len(folder.Pages)-1].Items) >= grid.FolderCapacity() {
+				folder.Pages = append(folder.Pages, FolderPage{Number: len(folder.Pages) + 1})
+			}
+			folder.Pages[len(folder.Pages)-1].Items = append(folder.Pages[len(folder.Pages)-1].Items, app)
+			return false, nil
+		}
+	}
+	a.appendItem(FolderItem(AppFolder{
+		Name:  name,
+		Pages: []FolderPage{{Number: 1, Items: []AppRef{app}}},
+	}), grid.PageCapacity())
+	return true, nil
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRule_compile(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    Rule
+		wantErr string
+	}{
+		{name: "criteria", rule: Rule{Folder: "Dev", Category: "public.app-category.developer-tools", BundlePrefix: "com.jetbrains.", Title: "^Py"}},
+		{name: "catch-all", rule: Rule{Folder: "Other"}},
+		{name: "no folder", rule: Rule{Title: "^Py"}, wantErr: "rule must have a folder"},
+		{name: "invalid title", rule: Rule{Folder: "Dev", Title: "Py("}, wantErr: "rule for folder 'Dev' has invalid title regex"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.compile()
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("Rule.compile() error = %v", err)
+				}
+				if (tt.rule.title != nil) != (len(tt.rule.Title) > 0) {
+					t.Errorf("Rule.compile() title regex = %v for title %q", tt.rule.title, tt.rule.Title)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Rule.compile() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRule_Match(t *testing.T) {
+	pycharm := App{Title: "PyCharm", BundleID: "com.jetbrains.pycharm"}
+	devTools := "public.app-category.developer-tools"
+
+	tests := []struct {
+		name     string
+		rule     Rule
+		app      App
+		category string
+		want     bool
+	}{
+		{name: "catch-all", rule: Rule{Folder: "Other"}, app: pycharm, want: true},
+		{name: "category", rule: Rule{Folder: "Dev", Category: devTools}, app: pycharm, category: devTools, want: true},
+		{name: "other category", rule: Rule{Folder: "Dev", Category: devTools}, app: pycharm, category: "public.app-category.games"},
+		{name: "bundle prefix", rule: Rule{Folder: "JetBrains", BundlePrefix: "com.jetbrains."}, app: pycharm, want: true},
+		{name: "other bundle prefix", rule: Rule{Folder: "Apple", BundlePrefix: "com.apple."}, app: pycharm},
+		{name: "title regex", rule: Rule{Folder: "Python", Title: "^Py"}, app: pycharm, want: true},
+		{name: "title regex no match", rule: Rule{Folder: "Python", Title: "^py$"}, app: pycharm},
+		{name: "all criteria", rule: Rule{Folder: "Dev", Category: devTools, BundlePrefix: "com.jetbrains.", Title: "Charm$"}, app: pycharm, category: devTools, want: true},
+		{name: "one criterion fails", rule: Rule{Folder: "Dev", Category: devTools, BundlePrefix: "com.jetbrains.", Title: "^Web"}, app: pycharm, category: devTools},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rule.compile(); err != nil {
+				t.Fatal(err)
+			}
+			if got := tt.rule.Match(tt.app, tt.category); got != tt.want {
+				t.Errorf("Rule.Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_route(t *testing.T) {
+	rules := []Rule{
+		{Folder: "Python", Title: "^Py"},
+		{Folder: "JetBrains", BundlePrefix: "com.jetbrains."},
+		{Folder: "Other"},
+	}
+	for idx := range rules {
+		if err := rules[idx].compile(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		rules  []Rule
+		app    App
+		want   string
+		wantOK bool
+	}{
+		{name: "first match wins", rules: rules, app: App{Title: "PyCharm", BundleID: "com.jetbrains.pycharm"}, want: "Python", wantOK: true},
+		{name: "later rule", rules: rules, app: App{Title: "GoLand", BundleID: "com.jetbrains.goland"}, want: "JetBrains", wantOK: true},
+		{name: "catch-all", rules: rules, app: App{Title: "Chess", BundleID: "com.apple.Chess"}, want: "Other", wantOK: true},
+		{name: "no catch-all", rules: rules[:2], app: App{Title: "Chess", BundleID: "com.apple.Chess"}},
+		{name: "no rules", app: App{Title: "Chess"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := route(tt.rules, tt.app, "")
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("route() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ErrUnknownSchema is returned when writing to a database whose schema lporg does not know
+var ErrUnknownSchema = errors.New("unknown launchpad database schema")
+
+// SchemaVersion identifies a known Launchpad database schema
+type SchemaVersion string
+
+// Known schema versions
+const (
+	SchemaUnknown SchemaVersion = "unknown"
+	// SchemaV1 is the layout lporg reads and writes. The Dashboard widgets table and
+	// root of older macOS versions are optional.
+	SchemaV1 SchemaVersion = "v1"
+)
+
+// triggersKey is the dbinfo key the item update triggers are gated on
+const triggersKey = "ignore_items_update_triggers"
+
+// Schema is the table, trigger and dbinfo layout found in a Launchpad database
+type Schema struct {
+	Version  SchemaVersion
+	Tables   map[string][]string // table name to column names
+	Triggers map[string]string   // trigger name to SQL
+	DBInfo   map[string]string
+	Problems []string // why the schema did not match a known version
+}
+
+// schemaSpec is what a known schema version requires. Extra tables, columns,
+// triggers and dbinfo keys are allowed.
+type schemaSpec struct {
+	version  SchemaVersion
+	tables   map[string][]string
+	triggers []string // update triggers that must be gated on triggersKey
+	dbinfo   []string
+}
+
+// knownSchemas are the schemas lporg writes to. The write operations (ClearGroups,
+// AddRootsAndHoldingPages, ApplyConfig, Reconcile, FixOther, GetMissing) use the v1
+// tables and row IDs directly, so any other schema is detected and refused rather
+// than adapted to.
+var knownSchemas = []schemaSpec{
+	{
+		version: SchemaV1,
+		tables: map[string][]string{
+			"dbinfo":     {"key", "value"},
+			"items":      {"rowid", "uuid", "flags", "type", "parent_id", "ordering"},
+			"apps":       {"item_id", "title", "bundleid", "storeid", "category_id", "moddate", "bookmark"},
+			"groups":     {"item_id", "category_id", "title"},
+			"categories": {"rowid", "uti"},
+		},
+		triggers: []string{"update_items_order", "update_items_order_backwards", "update_item_parent", "insert_item"},
+		dbinfo:   []string{triggersKey, "launchpad_root"},
+	},
+}
+
+// DetectSchema inspects the tables, columns, triggers and dbinfo of the database
+// and matches them against the known schema versions
+func DetectSchema(db *gorm.DB) (*Schema, error) {
+	var master []struct {
+		Type string
+		Name string
+		SQL  string
+	}
+	if err := db.Raw("SELECT type, name, IFNULL(sql, '') AS sql FROM sqlite_master WHERE type IN ('table', 'trigger')").Scan(&master).Error; err != nil {
+		return nil, fmt.Errorf("failed to read database schema: %w", err)
+	}
+
+	s := Schema{
+		Version:  SchemaUnknown,
+		Tables:   make(map[string][]string),
+		Triggers: make(map[string]string),
+		DBInfo:   make(map[string]string),
+	}
+	for _, obj := range master {
+		switch obj.Type {
+		case "table":
+			var columns []struct{ Name string }
+			if err := db.Raw(fmt.Sprintf("PRAGMA table_info(%s)", strconv.Quote(obj.Name))).Scan(&columns).Error; err != nil {
+				return nil, fmt.Errorf("failed to read columns of table '%s': %w", obj.Name, err)
+			}
+			for _, column := range columns {
+				s.Tables[obj.Name] = append(s.Tables[obj.Name], column.Name)
+			}
+		case "trigger":
+			s.Triggers[obj.Name] = obj.SQL
+		}
+	}
+	if _, ok := s.Tables["dbinfo"]; ok {
+		var dbinfo []DBInfo
+		if err := db.Find(&dbinfo).Error; err != nil {
+			return nil, fmt.Errorf("dbinfo query failed: %w", err)
+		}
+		for _, info := range dbinfo {
+			s.DBInfo[info.Key] = info.Value
+		}
+	}
+
+	for _, spec := range knownSchemas {
+		problems := spec.match(&s)
+		if len(problems) == 0 {
+			s.Version, s.Problems = spec.version, nil
+			break
+		}
+		if len(s.Problems) == 0 || len(problems) < len(s.Problems) { // report the closest version
+			s.Problems = problems
+		}
+	}
+
+	return &s, nil
+}
+
+// match returns what the schema is missing to be this version
+func (spec schemaSpec) match(s *Schema) []string {
+	var problems []string
+	names := make([]string, 0, len(spec.tables))
+	for name := range spec.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		columns, ok := s.Tables[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("missing table '%s'", name))
+			continue
+		}
+		for _, column := range spec.tables[name] {
+			// rowid is implicit unless the table declares it
+			if column != "rowid" && !containsFold(columns, column) {
+				problems = append(problems, fmt.Sprintf("missing column '%s.%s'", name, column))
+			}
+		}
+	}
+	for _, name := range spec.triggers {
+		sql, ok := s.Triggers[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("missing trigger '%s'", name))
+		} else if !strings.Contains(sql, triggersKey) {
+			problems = append(problems, fmt.Sprintf("trigger '%s' is not gated on '%s'", name, triggersKey))
+		}
+	}
+	for _, key := range spec.dbinfo {
+		if _, ok := s.DBInfo[key]; !ok {
+			problems = append(problems, fmt.Sprintf("missing dbinfo key '%s'", key))
+		}
+	}
+	return problems
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// err returns why lporg refuses to write to a database with this schema
+func (s *Schema) err() error {
+	return fmt.Errorf("refusing to write to launchpad database: %w: %s", ErrUnknownSchema, strings.Join(s.Problems, "; "))
+}
+
+// Schema returns the detected schema of the database, detecting it on first use
+func (lp *LaunchPad) Schema() (*Schema, error) {
+	if lp.schema == nil {
+		schema, err := DetectSchema(lp.DB)
+		if err != nil {
+			return nil, err
+		}
+		lp.schema = schema
+	}
+	return lp.schema, nil
+}
+
+// CheckWritable returns an error wrapping ErrUnknownSchema if lporg does not know the schema,
+// unless the database is a scratch copy
+func (lp *LaunchPad) CheckWritable() error {
+	schema, err := lp.Schema()
+	if err != nil {
+		return err
+	}
+	if schema.Version == SchemaUnknown && !lp.Scratch {
+		return schema.err()
+	}
+	return nil
+}
+
+// setTriggers turns the item update triggers on or off through their dbinfo key. A scratch
+// copy with an unknown schema is written to the same way if it has the key.
+func (lp *LaunchPad) setTriggers(enabled bool) error {
+	if err := lp.CheckWritable(); err != nil {
+		return err
+	}
+	if _, ok := lp.schema.DBInfo[triggersKey]; !ok {
+		return nil
+	}
+	value := 1
+	if enabled {
+		value = 0
+	}
+	if err := lp.DB.Exec("UPDATE dbinfo SET value = ? WHERE key = ?", value, triggersKey).Error; err != nil {
+		return fmt.Errorf("could not update `%s` to %d: %w", triggersKey, value, err)
+	}
+	return nil
+}
+
+// triggersDisabled returns true if the item update triggers are off
+func (lp *LaunchPad) triggersDisabled() (bool, error) {
+	schema, err := lp.Schema()
+	if err != nil {
+		return false, err
+	}
+	if _, ok := schema.DBInfo[triggersKey]; !ok {
+		return false, nil
+	}
+	var dbinfo DBInfo
+	if err := lp.DB.Where("key = ?", triggersKey).Find(&dbinfo).Error; err != nil {
+		return false, fmt.Errorf("dbinfo query failed: %w", err)
+	}
+	return dbinfo.Value == "1", nil
+}
+
+// roots returns the launchpad and dashboard root item IDs, the dashboard one is 0 if there is none
+func (lp *LaunchPad) roots() (launchpad, dashboard int, err error) {
+	schema, err := lp.Schema()
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, ok := schema.Tables["dbinfo"]; !ok {
+		return 1, 0, nil
+	}
+	var dbinfo []DBInfo
+	roots := map[string]int{"launchpad_root": 1}
+	if err := lp.DB.Where("key in (?)", []string{"launchpad_root", "dashboard_root"}).Find(&dbinfo).Error; err != nil {
+		return 0, 0, fmt.Errorf("dbinfo query failed: %w", err)
+	}
+	for _, info := range dbinfo {
+		if id, err := strconv.Atoi(info.Value); err == nil {
+			roots[info.Key] = id
+		}
+	}
+	return roots["launchpad_root"], roots["dashboard_root"], nil
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/blacktop/lporg/internal/database/testdb"
+)
+
+func TestDetectSchema(t *testing.T) {
+	tests := []struct {
+		name        string
+		alter       string
+		want        SchemaVersion
+		problems    []string
+		noDashboard bool
+	}{
+		{name: "v1", want: SchemaV1},
+		{
+			name:        "no dashboard",
+			alter:       "DROP TABLE widgets; DELETE FROM dbinfo WHERE key = 'dashboard_root';",
+			want:        SchemaV1,
+			noDashboard: true,
+		},
+		{
+			name:     "missing column",
+			alter:    "ALTER TABLE apps DROP COLUMN storeid;",
+			want:     SchemaUnknown,
+			problems: []string{"missing column 'apps.storeid'"},
+		},
+		{
+			name: "ungated trigger",
+			alter: `DROP TRIGGER insert_item;
+CREATE TRIGGER insert_item AFTER INSERT on items BEGIN UPDATE items SET ordering = 0 WHERE ROWID=new.rowid; END;`,
+			want:     SchemaUnknown,
+			problems: []string{"trigger 'insert_item' is not gated on 'ignore_items_update_triggers'"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lp := open(t, parse(t, "pages: [[Safari, {folder: Utilities, pages: [[Terminal]]}]]"))
+			if len(tt.alter) > 0 {
+				if err := lp.DB.Exec(tt.alter).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			schema, err := lp.Schema()
+			if err != nil {
+				t.Fatalf("Schema() error = %v", err)
+			}
+			if schema.Version != tt.want || !reflect.DeepEqual(schema.Problems, tt.problems) {
+				t.Errorf("Schema() = %s %v, want %s %v", schema.Version, schema.Problems, tt.want, tt.problems)
+			}
+
+			err = lp.Transaction(func() error { return nil })
+			if gotUnknown := errors.Is(err, ErrUnknownSchema); gotUnknown != (tt.want == SchemaUnknown) {
+				t.Errorf("Transaction() error = %v, want ErrUnknownSchema %v", err, tt.want == SchemaUnknown)
+			}
+
+			// reads work whatever the schema
+			layout, err := lp.ReadLayout()
+			if err != nil {
+				t.Fatalf("ReadLayout() error = %v", err)
+			}
+			if len(layout.Launchpad) != 1 || len(layout.Launchpad[0].Items) != 2 {
+				t.Errorf("ReadLayout() = %v, want 1 page of 2 items", layout.Launchpad)
+			}
+			if (layout.DashboardRoot == 0) != tt.noDashboard {
+				t.Errorf("ReadLayout() dashboard root = %d", layout.DashboardRoot)
+			}
+		})
+	}
+}
+
+func TestLaunchPad_DisableTriggers_unknownSchema(t *testing.T) {
+	lp := open(t, testdb.Generate(3, 35))
+	if err := lp.DB.Exec("DROP TRIGGER update_item_parent").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := lp.DisableTriggers(); !errors.Is(err, ErrUnknownSchema) {
+		t.Errorf("DisableTriggers() error = %v, want ErrUnknownSchema", err)
+	}
+	if lp.TriggersDisabled() {
+		t.Errorf("TriggersDisabled() = true after refusing to disable them")
+	}
+}
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	sqlite3 "github.com/glebarez/go-sqlite"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// sqliteBusy and sqliteLocked are the SQLITE_BUSY and SQLITE_LOCKED primary result codes
+const (
+	sqliteBusy   = 5
+	sqliteLocked = 6
+)
+
+// snapshotRetryDelay is how long Snapshot waits before retrying a busy database
+var snapshotRetryDelay = 100 * time.Millisecond
+
+// DSN returns the data source name for the database file that waits up to timeout
+// for a lock held by another connection, e.g. the Dock's, before failing with SQLITE_BUSY
+func DSN(file string, timeout time.Duration) string {
+	return fmt.Sprintf("file:%s?_pragma=busy_timeout(%d)", uriEscaper.Replace(file), timeout.Milliseconds())
+}
+
+// IsBusy returns true if err is SQLITE_BUSY or SQLITE_LOCKED
+func IsBusy(err error) bool {
+	var serr *sqlite3.Error
+	if errors.As(err, &serr) {
+		code := serr.Code() & 0xff // strip the extended result code
+		return code == sqliteBusy || code == sqliteLocked
+	}
+	return false
+}
+
+// Snapshot writes a transactionally consistent copy of the database in src to dst,
+// including the changes still in its write-ahead log. It retries while the database is
+// busy until timeout and checks the copy before returning. dst must not exist.
+func Snapshot(src, dst string, timeout time.Duration) error {
+	if _, err := os.Stat(src); err != nil {
+		return fmt.Errorf("%w: %v", ErrDBNotFound, err)
+	}
+
+	db, err := gorm.Open(sqlite.Open(DSN(src, timeout)), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to open launchpad DB: %w", err)
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+
+	// VACUUM INTO copies the database as of a single read transaction
+	deadline := time.Now().Add(timeout)
+	for {
+		err = db.Exec("VACUUM INTO ?", dst).Error
+		if err == nil || !IsBusy(err) || time.Now().After(deadline) {
+			break
+		}
+		os.Remove(dst) // VACUUM INTO refuses to overwrite a partial copy
+		time.Sleep(snapshotRetryDelay)
+	}
+	if err != nil {
+		if IsBusy(err) {
+			return fmt.Errorf("launchpad DB still busy after %s: %w", timeout, err)
+		}
+		return fmt.Errorf("failed to snapshot launchpad DB: %w", err)
+	}
+
+	return checkSnapshot(dst)
+}
+
+// checkSnapshot runs a quick integrity check on the copied database
+func checkSnapshot(file string) error {
+	db, err := gorm.Open(sqlite.Open(file), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to open launchpad DB snapshot: %w", err)
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+	var result []string
+	if err := db.Raw("PRAGMA quick_check").Scan(&result).Error; err != nil {
+		return fmt.Errorf("failed to check launchpad DB snapshot: %w", err)
+	}
+	if len(result) != 1 || result[0] != "ok" {
+		return fmt.Errorf("launchpad DB snapshot is corrupt: %v", result)
+	}
+	return nil
+}
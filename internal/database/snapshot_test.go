@@ -0,0 +1,135 @@
+package database
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blacktop/lporg/internal/database/testdb"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestSnapshot(t *testing.T) {
+	tests := []struct {
+		name    string
+		hold    time.Duration // how long another connection holds an exclusive lock
+		timeout time.Duration
+		wantErr bool
+	}{
+		{name: "unlocked", timeout: time.Second},
+		{name: "wal", timeout: time.Second},
+		{name: "locked briefly", hold: 200 * time.Millisecond, timeout: 5 * time.Second},
+		{name: "locked", hold: 2 * time.Second, timeout: 300 * time.Millisecond, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lp := newLaunchPad(t, 3)
+			sqlDB, err := lp.DB.DB()
+			if err != nil {
+				t.Fatal(err)
+			}
+			conn, err := sqlDB.Conn(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			if tt.name == "wal" {
+				// a change left in the WAL must be in the snapshot
+				for _, stmt := range []string{"PRAGMA journal_mode=WAL", "PRAGMA wal_autocheckpoint=0", "UPDATE apps SET title = 'Changed' WHERE title = 'App 0000'"} {
+					if _, err := conn.ExecContext(context.Background(), stmt); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			if tt.hold > 0 {
+				if _, err := conn.ExecContext(context.Background(), "BEGIN EXCLUSIVE"); err != nil {
+					t.Fatal(err)
+				}
+				timer := time.AfterFunc(tt.hold, func() { conn.ExecContext(context.Background(), "COMMIT") })
+				defer timer.Stop()
+			}
+
+			dst := filepath.Join(t.TempDir(), "db")
+			err = Snapshot(lp.File, dst, tt.timeout)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Snapshot() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !IsBusy(err) {
+					t.Errorf("Snapshot() error = %v, want SQLITE_BUSY", err)
+				}
+				return
+			}
+
+			db, err := gorm.Open(sqlite.Open(dst), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			snap := &LaunchPad{DB: db, File: dst}
+			defer snap.Close()
+			var titles []string
+			if err := snap.DB.Raw("SELECT title FROM apps ORDER BY title").Scan(&titles).Error; err != nil {
+				t.Fatal(err)
+			}
+			want := "App 0000"
+			if tt.name == "wal" {
+				want = "App 0001"
+			}
+			if len(titles) != 3 || titles[0] != want {
+				t.Errorf("snapshot apps = %v, want 3 starting with %s", titles, want)
+			}
+		})
+	}
+}
+
+func TestDSN(t *testing.T) {
+	tests := []struct {
+		name string
+		dir  string
+	}{
+		{name: "plain", dir: "plain"},
+		{name: "query", dir: "what?mode=memory"},
+		{name: "fragment", dir: "launchpad#1"},
+		{name: "percent", dir: "100%25"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// testdb opens the raw path, so create the database before moving it under the name
+			tmp := t.TempDir()
+			if err := testdb.Create(filepath.Join(tmp, "plain", "db"), testdb.Generate(3, 35)); err != nil {
+				t.Fatal(err)
+			}
+			dir := filepath.Join(tmp, tt.dir)
+			if tt.dir != "plain" {
+				if err := os.Rename(filepath.Join(tmp, "plain"), dir); err != nil {
+					t.Fatal(err)
+				}
+			}
+			file := filepath.Join(dir, "db")
+
+			db, err := gorm.Open(sqlite.Open(DSN(file, time.Second)), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			lp := &LaunchPad{DB: db, File: file}
+			defer lp.Close()
+			var count int64
+			if err := lp.DB.Table("apps").Count(&count).Error; err != nil {
+				t.Fatalf("DSN() did not open %s: %v", file, err)
+			}
+			if count != 3 {
+				t.Errorf("DSN() opened a database with %d apps, want 3", count)
+			}
+
+			dst := filepath.Join(t.TempDir(), "snapshot")
+			if err := Snapshot(file, dst, time.Second); err != nil {
+				t.Errorf("Snapshot() error = %v", err)
+			}
+		})
+	}
+}
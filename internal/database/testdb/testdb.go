@@ -0,0 +1,330 @@
+// Package testdb creates Launchpad databases with Apple's schema and triggers for tests
+package testdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	yaml "gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Item types in the items table
+const (
+	RootType        = 1
+	FolderRootType  = 2
+	PageType        = 3
+	ApplicationType = 4
+)
+
+// LaunchpadRoot is the item ID of the launchpad root page
+const LaunchpadRoot = 1
+
+// Schema is the Launchpad database schema, update triggers and root and holding pages.
+// dbinfo is filled in last so the insert trigger leaves the root orderings alone.
+const Schema = `
+CREATE TABLE dbinfo (key VARCHAR, value VARCHAR);
+CREATE TABLE items (rowid INTEGER PRIMARY KEY ASC, uuid VARCHAR, flags INTEGER, type INTEGER, parent_id INTEGER NOT NULL, ordering INTEGER);
+CREATE TABLE apps (item_id INTEGER PRIMARY KEY, title VARCHAR, bundleid VARCHAR, storeid VARCHAR,category_id INTEGER, moddate REAL, bookmark BLOB);
+CREATE TABLE groups (item_id INTEGER PRIMARY KEY, category_id INTEGER, title VARCHAR);
+CREATE TABLE widgets (item_id INTEGER PRIMARY KEY, title VARCHAR, bundleid VARCHAR, storeid VARCHAR,category_id INTEGER, moddate REAL, bookmark BLOB);
+CREATE TABLE categories (rowid INTEGER PRIMARY KEY ASC, uti VARCHAR);
+CREATE TRIGGER update_items_order BEFORE UPDATE OF ordering ON items WHEN new.ordering > old.ordering AND 0 == (SELECT value FROM dbinfo WHERE key='ignore_items_update_triggers')
+BEGIN
+UPDATE items SET ordering = ordering - 1 WHERE parent_id = old.parent_id AND ordering BETWEEN old.ordering and new.ordering;
+END;
+CREATE TRIGGER update_items_order_backwards BEFORE UPDATE OF ordering ON items WHEN new.ordering < old.ordering AND 0 == (SELECT value FROM dbinfo WHERE key='ignore_items_update_triggers')
+BEGIN
+UPDATE items SET ordering = ordering + 1 WHERE parent_id = old.parent_id AND ordering BETWEEN new.ordering and old.ordering;
+END;
+CREATE TRIGGER update_item_parent AFTER UPDATE OF parent_id ON items WHEN 0 == (SELECT value FROM dbinfo WHERE key='ignore_items_update_triggers')
+BEGIN
+UPDATE items SET ordering = (SELECT ifnull(MAX(ordering),0)+1 FROM items WHERE parent_id=new.parent_id AND ROWID!=old.rowid) WHERE ROWID=old.rowid;
+UPDATE items SET ordering = ordering - 1 WHERE parent_id = old.parent_id and ordering > old.ordering;
+END;
+CREATE TRIGGER insert_item AFTER INSERT on items WHEN 0 == (SELECT value FROM dbinfo WHERE key='ignore_items_update_triggers')
+BEGIN
+UPDATE items SET ordering = (SELECT ifnull(MAX(ordering),0)+1 FROM items WHERE parent_id=new.parent_id) WHERE ROWID=new.rowid;
+END;
+CREATE TRIGGER item_deleted AFTER DELETE ON items
+BEGIN
+DELETE FROM apps WHERE rowid=old.rowid;
+DELETE FROM groups WHERE item_id=old.rowid;
+DELETE FROM widgets WHERE rowid=old.rowid;
+UPDATE items SET ordering = ordering - 1 WHERE old.parent_id = parent_id AND ordering > old.ordering;
+END;
+CREATE TRIGGER app_deleted AFTER DELETE ON apps BEGIN DELETE FROM items WHERE rowid=old.item_id; END;
+CREATE TRIGGER group_deleted AFTER DELETE ON groups BEGIN DELETE FROM items WHERE rowid=old.item_id; END;
+INSERT INTO items VALUES (1,'ROOTPAGE',0,1,0,0),(2,'HOLDINGPAGE',0,3,1,0),(3,'ROOTPAGE_DB',0,1,0,0),(4,'HOLDINGPAGE_DB',0,3,3,0),(5,'ROOTPAGE_VERS',0,1,0,0),(6,'HOLDINGPAGE_VERS',0,3,5,0);
+INSERT INTO groups (item_id) VALUES (1),(2),(3),(4),(5),(6);
+INSERT INTO dbinfo VALUES ('ignore_items_update_triggers','0'),('launchpad_root','1'),('dashboard_root','3');
+`
+
+// App is an installed app. In YAML it is either its title or a mapping.
+type App struct {
+	Title    string `yaml:"title"`
+	Bundle   string `yaml:"bundle,omitempty"`
+	Category string `yaml:"category,omitempty"` // category UTI, e.g. public.app-category.games
+}
+
+// UnmarshalYAML decodes an app from its title or a mapping
+func (a *App) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		a.Title = node.Value
+		return nil
+	}
+	type plain App
+	return node.Decode((*plain)(a))
+}
+
+// Item is an app or a folder on a launchpad page
+type Item struct {
+	App    *App
+	Folder string
+	Pages  [][]App // folder pages
+}
+
+// UnmarshalYAML decodes an app title or mapping, or a mapping with a folder key and its pages
+func (i *Item) UnmarshalYAML(node *yaml.Node) error {
+	var item struct {
+		Title    string  `yaml:"title"`
+		Bundle   string  `yaml:"bundle"`
+		Category string  `yaml:"category"`
+		Folder   string  `yaml:"folder"`
+		Pages    [][]App `yaml:"pages"`
+	}
+	if node.Kind == yaml.ScalarNode {
+		item.Title = node.Value
+	} else if err := node.Decode(&item); err != nil {
+		return err
+	}
+	if len(item.Folder) > 0 {
+		i.Folder, i.Pages = item.Folder, item.Pages
+		return nil
+	}
+	if len(item.Title) == 0 && len(item.Bundle) == 0 {
+		return fmt.Errorf("line %d: item needs a title, a bundle or a folder", node.Line)
+	}
+	i.App = &App{Title: item.Title, Bundle: item.Bundle, Category: item.Category}
+	return nil
+}
+
+// Layout describes the launchpad pages of a database:
+//
+//	pages:
+//	  - - Safari
+//	    - {title: Xcode, bundle: com.apple.dt.Xcode, category: public.app-category.developer-tools}
+//	    - folder: Utilities
+//	      pages:
+//	        - [Terminal, Chess]
+type Layout struct {
+	Pages [][]Item `yaml:"pages"`
+}
+
+// Parse decodes a YAML layout description
+func Parse(data []byte) (Layout, error) {
+	var layout Layout
+	if err := yaml.Unmarshal(data, &layout); err != nil {
+		return Layout{}, fmt.Errorf("failed to parse layout: %w", err)
+	}
+	return layout, nil
+}
+
+// Title returns the title of the nth generated app
+func Title(n int) string {
+	return fmt.Sprintf("App %04d", n)
+}
+
+// Bundle returns the bundle identifier of the nth generated app
+func Bundle(n int) string {
+	return fmt.Sprintf("com.example.app%04d", n)
+}
+
+// categories are cycled through by Generate
+var categories = []string{
+	"public.app-category.developer-tools",
+	"public.app-category.productivity",
+	"public.app-category.utilities",
+	"public.app-category.games",
+	"public.app-category.music",
+}
+
+// Generate returns a layout of n apps, perPage to a page, named by Title and Bundle
+func Generate(n, perPage int) Layout {
+	apps := make([]App, n)
+	for i := range apps {
+		apps[i] = App{Title: Title(i), Bundle: Bundle(i), Category: categories[i%len(categories)]}
+	}
+	return Flat(apps, perPage)
+}
+
+// Flat returns a layout of the apps in order, perPage to a page and without folders
+func Flat(apps []App, perPage int) Layout {
+	var layout Layout
+	for i := range apps {
+		if i%perPage == 0 {
+			layout.Pages = append(layout.Pages, nil)
+		}
+		layout.Pages[len(layout.Pages)-1] = append(layout.Pages[len(layout.Pages)-1], Item{App: &apps[i]})
+	}
+	return layout
+}
+
+// Apps returns every app in the layout in page order
+func (l Layout) Apps() []App {
+	var apps []App
+	for _, page := range l.Pages {
+		for _, item := range page {
+			if item.App != nil {
+				apps = append(apps, *item.App)
+			}
+			for _, fpage := range item.Pages {
+				apps = append(apps, fpage...)
+			}
+		}
+	}
+	return apps
+}
+
+// New creates the database described by layout in a temporary directory and returns its path
+func New(tb testing.TB, layout Layout) string {
+	tb.Helper()
+	path := filepath.Join(tb.TempDir(), "db")
+	if err := Create(path, layout); err != nil {
+		tb.Fatal(err)
+	}
+	return path
+}
+
+// Create writes the database described by layout to path. App items are numbered
+// from 7 in page order and get the UUID "app-<id>"; pages and folders follow them
+// the way they do after Launchpad or lporg build the layout.
+func Create(path string, layout Layout) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return fmt.Errorf("failed to create database dir: %w", err)
+	}
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+
+	if err := db.Exec(Schema).Error; err != nil {
+		return fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		// the fixture sets every ordering itself
+		if err := tx.Exec("UPDATE dbinfo SET value=1 WHERE key='ignore_items_update_triggers'").Error; err != nil {
+			return err
+		}
+
+		w := writer{tx: tx, nextID: 6, categories: make(map[string]int)}
+
+		apps := layout.Apps()
+		appIDs := make([]int, len(apps))
+		for idx, app := range apps {
+			id, err := w.app(app)
+			if err != nil {
+				return err
+			}
+			appIDs[idx] = id
+		}
+
+		// apps are placed in the same page order they were numbered in
+		next := 0
+		placeApp := func(parentID, ordering int) error {
+			id := appIDs[next]
+			next++
+			return tx.Exec("UPDATE items SET parent_id = ?, ordering = ? WHERE rowid = ?", parentID, ordering, id).Error
+		}
+		for pidx, page := range layout.Pages {
+			pageID, err := w.group("page", 2, PageType, LaunchpadRoot, pidx+1, "")
+			if err != nil {
+				return err
+			}
+			for idx, item := range page {
+				if item.App != nil {
+					if err := placeApp(pageID, idx); err != nil {
+						return err
+					}
+					continue
+				}
+				folderID, err := w.group("folder", 0, FolderRootType, pageID, idx, item.Folder)
+				if err != nil {
+					return err
+				}
+				for fidx, fpage := range item.Pages {
+					fpageID, err := w.group("page", 2, PageType, folderID, fidx+1, "")
+					if err != nil {
+						return err
+					}
+					for aidx := range fpage {
+						if err := placeApp(fpageID, aidx); err != nil {
+							return err
+						}
+					}
+				}
+			}
+		}
+		return tx.Exec("UPDATE dbinfo SET value=0 WHERE key='ignore_items_update_triggers'").Error
+	})
+}
+
+type writer struct {
+	tx         *gorm.DB
+	nextID     int
+	categories map[string]int
+}
+
+// app inserts an app item on the holding page and its apps row
+func (w *writer) app(app App) (int, error) {
+	w.nextID++
+	id := w.nextID
+
+	var categoryID any
+	if len(app.Category) > 0 {
+		cid, ok := w.categories[app.Category]
+		if !ok {
+			cid = len(w.categories) + 1
+			w.categories[app.Category] = cid
+			if err := w.tx.Exec("INSERT INTO categories VALUES (?,?)", cid, app.Category).Error; err != nil {
+				return 0, fmt.Errorf("failed to insert category '%s': %w", app.Category, err)
+			}
+		}
+		categoryID = cid
+	}
+
+	if err := w.tx.Exec("INSERT INTO items VALUES (?,?,0,?,2,0)", id, fmt.Sprint("app-", id), ApplicationType).Error; err != nil {
+		return 0, fmt.Errorf("failed to insert item for app '%s': %w", app.Title, err)
+	}
+	if err := w.tx.Exec("INSERT INTO apps (item_id,title,bundleid,category_id,moddate) VALUES (?,?,?,?,0)",
+		id, app.Title, app.Bundle, categoryID).Error; err != nil {
+		return 0, fmt.Errorf("failed to insert app '%s': %w", app.Title, err)
+	}
+	return id, nil
+}
+
+// group inserts a page or folder item and its groups row
+func (w *writer) group(kind string, flags, itemType, parentID, ordering int, title string) (int, error) {
+	w.nextID++
+	id := w.nextID
+
+	var groupTitle any
+	if len(title) > 0 {
+		groupTitle = title
+	}
+	if err := w.tx.Exec("INSERT INTO items VALUES (?,?,?,?,?,?)", id, fmt.Sprintf("%s-%d", kind, id), flags, itemType, parentID, ordering).Error; err != nil {
+		return 0, fmt.Errorf("failed to insert %s item: %w", kind, err)
+	}
+	if err := w.tx.Exec("INSERT INTO groups (item_id,title) VALUES (?,?)", id, groupTitle).Error; err != nil {
+		return 0, fmt.Errorf("failed to insert %s group: %w", kind, err)
+	}
+	return id, nil
+}
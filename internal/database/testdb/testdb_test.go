@@ -0,0 +1,75 @@
+package testdb
+
+import (
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestCreate(t *testing.T) {
+	folders, err := Parse([]byte(`
+pages:
+  - - Safari
+    - {title: Xcode, bundle: com.apple.dt.Xcode, category: public.app-category.developer-tools}
+    - folder: Utilities
+      pages:
+        - [Terminal, Chess]
+        - [{title: Notes, category: public.app-category.productivity}]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		layout     Layout
+		apps       int64
+		groups     int64
+		categories int64
+	}{
+		{name: "folders", layout: folders, apps: 5, groups: 6 + 4, categories: 2},
+		{name: "generated", layout: Generate(2000, 35), apps: 2000, groups: 6 + 58, categories: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := gorm.Open(sqlite.Open(New(t, tt.layout)), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sqlDB, err := db.DB(); err == nil {
+				defer sqlDB.Close()
+			}
+
+			for table, want := range map[string]int64{"apps": tt.apps, "groups": tt.groups, "categories": tt.categories} {
+				var got int64
+				if err := db.Table(table).Count(&got).Error; err != nil {
+					t.Fatal(err)
+				}
+				if got != want {
+					t.Errorf("Create() %s = %d, want %d", table, got, want)
+				}
+			}
+
+			// every app is at its position on its page with no gaps
+			var gaps int64
+			if err := db.Table("items AS a").
+				Where("a.type = ? AND a.ordering > 0", ApplicationType).
+				Where("NOT EXISTS (SELECT 1 FROM items b WHERE b.parent_id = a.parent_id AND b.ordering = a.ordering - 1)").
+				Count(&gaps).Error; err != nil {
+				t.Fatal(err)
+			}
+			if gaps != 0 {
+				t.Errorf("Create() left %d apps after a gap in their page ordering", gaps)
+			}
+			var trigger string
+			if err := db.Table("dbinfo").Select("value").Where("key = ?", "ignore_items_update_triggers").Scan(&trigger).Error; err != nil {
+				t.Fatal(err)
+			}
+			if trigger != "0" {
+				t.Errorf("Create() left ignore_items_update_triggers = %s, want 0", trigger)
+			}
+		})
+	}
+}
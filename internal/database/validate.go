@@ -0,0 +1,348 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Diagnostic is a problem found at a position in a config file
+type Diagnostic struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s", d.File, d.Line, d.Column, d.Message)
+}
+
+// Validate checks the config file and returns every problem found in file order
+func Validate(filename string) ([]Diagnostic, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrConfigNotFound, err)
+	}
+	return ValidateYAML(filename, data), nil
+}
+
+// ValidateYAML checks the config YAML and returns every problem found in file order
+func ValidateYAML(filename string, data []byte) []Diagnostic {
+	v := validator{file: filename}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		v.addError(&doc, err)
+		return v.diags
+	}
+	if len(doc.Content) == 0 {
+		v.diags = append(v.diags, Diagnostic{File: filename, Line: 1, Column: 1, Message: "config is empty"})
+		return v.diags
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		v.add(root, "config must be a mapping")
+		return v.diags
+	}
+
+	v.checkKeys(root, reflect.TypeOf(Config{}))
+
+	var grid Grid
+	if node := mapValue(root, "grid"); node != nil {
+		v.decode(node, &grid)
+	}
+	grid = grid.WithDefaults(Grid{})
+	v.checkApps(mapValue(root, "apps"), grid)
+	v.checkApps(mapValue(root, "widgets"), grid)
+
+	if node := mapValue(root, "rules"); node != nil && node.Kind == yaml.SequenceNode {
+		for _, rnode := range node.Content {
+			var rule Rule
+			if !v.decode(rnode, &rule) {
+				continue
+			}
+			if err := rule.compile(); err != nil {
+				v.add(rnode, err.Error())
+			}
+		}
+	}
+	if node := mapValue(root, "missing"); node != nil {
+		v.decode(node, &Placement{})
+	}
+	if node := mapValue(root, "desktop"); node != nil {
+		v.decode(node, &Desktop{})
+	}
+	if node := mapValue(root, "dock_items"); node != nil {
+		var dock Dock
+		if v.decode(node, &dock) && dock.Settings != nil {
+			if err := dock.Settings.Verify(); err != nil {
+				v.add(mapValue(node, "settings"), err.Error())
+			}
+		}
+	}
+
+	sort.SliceStable(v.diags, func(i, j int) bool {
+		if v.diags[i].Line != v.diags[j].Line {
+			return v.diags[i].Line < v.diags[j].Line
+		}
+		return v.diags[i].Column < v.diags[j].Column
+	})
+
+	return v.diags
+}
+
+type validator struct {
+	file  string
+	diags []Diagnostic
+	apps  map[string]*yaml.Node
+}
+
+func (v *validator) add(node *yaml.Node, format string, args ...any) {
+	v.diags = append(v.diags, Diagnostic{
+		File:    v.file,
+		Line:    node.Line,
+		Column:  node.Column,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+var yamlLineRE = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// addError adds a diagnostic for every error yaml reports, using the line it
+// reports when there is one and the node position otherwise
+func (v *validator) addError(node *yaml.Node, err error) {
+	msgs := []string{err.Error()}
+	if terr, ok := err.(*yaml.TypeError); ok {
+		msgs = terr.Errors
+	}
+	for _, msg := range msgs {
+		d := Diagnostic{File: v.file, Line: node.Line, Column: node.Column, Message: msg}
+		if m := yamlLineRE.FindStringSubmatch(msg); m != nil {
+			line, _ := strconv.Atoi(m[1])
+			if line != node.Line {
+				d.Line, d.Column = line, 0
+			}
+			d.Message = m[2]
+		}
+		v.diags = append(v.diags, d)
+	}
+}
+
+// decode decodes the node into out and reports any error at the node
+func (v *validator) decode(node *yaml.Node, out any) bool {
+	if err := node.Decode(out); err != nil {
+		v.addError(node, err)
+		return false
+	}
+	return true
+}
+
+// checkKeys reports mapping keys that are not fields of the type the node decodes into
+func (v *validator) checkKeys(node *yaml.Node, t reflect.Type) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t {
+	case reflect.TypeOf(PageItem{}):
+		if mapValue(node, "folder") != nil {
+			v.checkKeys(node, reflect.TypeOf(AppFolder{}))
+		} else {
+			v.checkKeys(node, reflect.TypeOf(AppRef{}))
+		}
+		return
+	case reflect.TypeOf(Placement{}):
+		t = reflect.TypeOf(struct {
+			Folder string `yaml:"folder"`
+		}{})
+	}
+
+	switch t.Kind() {
+	case reflect.Slice:
+		if node.Kind == yaml.SequenceNode {
+			for _, elem := range node.Content {
+				v.checkKeys(elem, t.Elem())
+			}
+		}
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		fields := yamlFields(t)
+		for idx := 0; idx+1 < len(node.Content); idx += 2 {
+			key, value := node.Content[idx], node.Content[idx+1]
+			field, ok := fields[key.Value]
+			if !ok {
+				v.add(key, "unknown key '%s' (must be one of: %s)", key.Value, strings.Join(sortedKeys(fields), ", "))
+				continue
+			}
+			v.checkKeys(value, field)
+		}
+	}
+}
+
+// checkApps checks page and folder page numbering and capacity, duplicate
+// folder names and apps that are listed more than once
+func (v *validator) checkApps(node *yaml.Node, grid Grid) {
+	pages := mapValue(node, "pages")
+	if pages == nil || pages.Kind != yaml.SequenceNode {
+		return
+	}
+	v.apps = make(map[string]*yaml.Node)
+	folders := make(map[string]*yaml.Node)
+	for pidx, pnode := range pages.Content {
+		if num := mapValue(pnode, "number"); num == nil {
+			v.add(pnode, "page %d is missing its number", pidx+1)
+		} else {
+			v.checkNumber(num, "page", pidx+1)
+		}
+		items := mapValue(pnode, "items")
+		if items == nil || items.Kind != yaml.SequenceNode {
+			continue
+		}
+		if len(items.Content) > grid.PageCapacity() {
+			v.add(items, "page %d has %d items but only %d fit on a %dx%d grid",
+				pidx+1, len(items.Content), grid.PageCapacity(), grid.Rows, grid.Columns)
+		}
+		for _, inode := range items.Content {
+			var item PageItem
+			if !v.decode(inode, &item) {
+				continue
+			}
+			if item.App != nil {
+				v.checkDuplicateApp(inode, *item.App)
+				continue
+			}
+			name := mapValue(inode, "folder")
+			if inode.Kind == yaml.AliasNode {
+				name = inode // report where the folder is reused, not where it is anchored
+			}
+			if first, ok := folders[item.Folder.Name]; ok {
+				v.add(name, "folder '%s' is defined more than once (first at line %d)", item.Folder.Name, first.Line)
+				if inode.Kind == yaml.AliasNode {
+					continue // its apps were checked where it is anchored
+				}
+			} else {
+				folders[item.Folder.Name] = name
+			}
+			v.checkFolder(inode, *item.Folder, grid)
+		}
+	}
+}
+
+func (v *validator) checkFolder(node *yaml.Node, folder AppFolder, grid Grid) {
+	fpages := mapValue(node, "pages")
+	if fpages == nil || fpages.Kind != yaml.SequenceNode || len(folder.Pages) == 0 {
+		v.add(node, "folder '%s' has no pages", folder.Name)
+		return
+	}
+	if len(folder.Pages[0].Items) == 0 {
+		v.add(fpages.Content[0], "folder '%s' must contain at least 1 app", folder.Name)
+	}
+	for fidx, fpnode := range fpages.Content {
+		if num := mapValue(fpnode, "number"); num != nil { // optional for folder pages
+			v.checkNumber(num, fmt.Sprintf("folder '%s' page", folder.Name), fidx+1)
+		}
+		items := mapValue(fpnode, "items")
+		if items == nil || items.Kind != yaml.SequenceNode {
+			continue
+		}
+		if len(items.Content) > grid.FolderCapacity() {
+			v.add(items, "folder '%s' page %d has %d apps but only %d fit on a %dx%d folder grid",
+				folder.Name, fidx+1, len(items.Content), grid.FolderCapacity(), grid.FolderRows, grid.FolderColumns)
+		}
+		for aidx, anode := range items.Content {
+			v.checkDuplicateApp(anode, folder.Pages[fidx].Items[aidx])
+		}
+	}
+}
+
+// checkNumber reports page numbers that are not the page's position
+func (v *validator) checkNumber(node *yaml.Node, what string, want int) {
+	var got int
+	if !v.decode(node, &got) {
+		return
+	}
+	if got != want {
+		v.add(node, "%s number %d is out of order (expected %d)", what, got, want)
+	}
+}
+
+// checkDuplicateApp reports apps whose title or bundle identifier was already listed
+func (v *validator) checkDuplicateApp(node *yaml.Node, app AppRef) {
+	var keys []string
+	if len(app.Bundle) > 0 {
+		keys = append(keys, "bundle:"+app.Bundle)
+	}
+	if len(app.Title) > 0 {
+		keys = append(keys, "title:"+app.Title)
+	}
+	var first *yaml.Node
+	for _, key := range keys {
+		if prev, ok := v.apps[key]; ok && first == nil {
+			first = prev
+		} else if !ok {
+			v.apps[key] = node
+		}
+	}
+	if first != nil {
+		v.add(node, "app '%s' is listed more than once (first at line %d)", app, first.Line)
+	}
+}
+
+// mapValue returns the value of the key in a mapping node, following aliases
+func mapValue(node *yaml.Node, key string) *yaml.Node {
+	node = resolveAlias(node)
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for idx := 0; idx+1 < len(node.Content); idx += 2 {
+		if node.Content[idx].Value == key {
+			return resolveAlias(node.Content[idx+1])
+		}
+	}
+	return nil
+}
+
+// resolveAlias returns the node an alias refers to and any other node as is
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node != nil && node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// yamlFields returns the yaml keys of a struct type and their field types
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for idx := 0; idx < t.NumField(); idx++ {
+		f := t.Field(idx)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if len(name) == 0 {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+func sortedKeys(m map[string]reflect.Type) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
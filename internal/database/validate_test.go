@@ -0,0 +1,123 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{
+			name: "valid",
+			data: `
+apps:
+  pages:
+    - number: 1
+      items:
+        - Safari
+        - folder: Dev
+          pages:
+            - items: [Xcode, {bundle: com.apple.Terminal}]
+dock_items:
+  settings:
+    tilesize: 48
+`,
+		},
+		{
+			name: "duplicates",
+			data: `
+apps:
+  pages:
+    - number: 1
+      items:
+        - Safari
+        - {title: Safari, bundle: com.apple.Safari}
+        - folder: Dev
+          pages:
+            - items: [Xcode]
+        - folder: Dev
+          pages:
+            - items: [{bundle: com.apple.Safari}]
+`,
+			want: []string{
+				"c.yml:7:11: app 'Safari' is listed more than once (first at line 6)",
+				"c.yml:11:19: folder 'Dev' is defined more than once (first at line 8)",
+				"c.yml:13:23: app 'com.apple.Safari' is listed more than once (first at line 7)",
+			},
+		},
+		{
+			name: "aliases",
+			data: `
+apps:
+  pages:
+    - number: 1
+      items:
+        - &safari Safari
+        - &dev
+          folder: Dev
+          pages:
+            - items: [Xcode]
+    - number: 2
+      items:
+        - *safari
+        - *dev
+`,
+			want: []string{
+				"c.yml:13:11: app 'Safari' is listed more than once (first at line 6)",
+				"c.yml:14:11: folder 'Dev' is defined more than once (first at line 8)",
+			},
+		},
+		{
+			name: "page numbers and capacity",
+			data: `
+grid: {rows: 1, columns: 2}
+apps:
+  pages:
+    - number: 2
+      items: [A, B, C]
+    - items: [D]
+`,
+			want: []string{
+				"c.yml:5:15: page number 2 is out of order (expected 1)",
+				"c.yml:6:14: page 1 has 3 items but only 2 fit on a 1x2 grid",
+				"c.yml:7:7: page 2 is missing its number",
+			},
+		},
+		{
+			name: "unknown keys and invalid values",
+			data: `
+apps:
+  pages:
+    - number: 1
+      items: [{titel: Safari}]
+missing: sideways
+dock_items:
+  settings:
+    tilesize: 200
+colour: red
+`,
+			want: []string{
+				"c.yml:5:15: config app must have a title or a bundle",
+				"c.yml:5:16: unknown key 'titel' (must be one of: bundle, title)",
+				"c.yml:6:10: unknown placement policy 'sideways' (must be one of: append, new-page, folder:<name>, alphabetical-insert, ignore)",
+				"c.yml:9:5: tilesize must be between 16 and 128: 200",
+				"c.yml:10:1: unknown key 'colour' (must be one of: apps, desktop, dock_items, grid, missing, rules, widgets)",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, d := range ValidateYAML("c.yml", []byte(tt.data)) {
+				got = append(got, d.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateYAML() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
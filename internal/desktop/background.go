@@ -1,20 +1,26 @@
 package desktop
 
 import (
+	"context"
 	"errors"
-	"os/exec"
 	"regexp"
 	"strings"
+	"time"
+
+	"github.com/blacktop/lporg/internal/runner"
 )
 
+// osascriptTimeout bounds scripts that wait on Finder or a dialog
+const osascriptTimeout = time.Minute
+
 // SetDesktopImage sets the desktop background image to the supplied image
-func SetDesktopImage(image string) (string, error) {
-	return Tell("Finder", "set desktop picture to POSIX file "+wrapInQuotes(image))
+func SetDesktopImage(ctx context.Context, image string) (string, error) {
+	return Tell(ctx, "Finder", "set desktop picture to POSIX file "+wrapInQuotes(image))
 }
 
 // Tell tells an macOS application what to do
-func Tell(application string, commands ...string) (string, error) {
-	return run(buildTell(application, commands...))
+func Tell(ctx context.Context, application string, commands ...string) (string, error) {
+	return run(ctx, buildTell(application, commands...))
 }
 
 // Parse the Tell options and build the command
@@ -29,14 +35,17 @@ func buildTell(application string, commands ...string) string {
 }
 
 // Build the AppleScript command from a set of optional parameters, return the output
-func run(command string) (string, error) {
-	cmd := exec.Command("osascript", "-e", command)
-	output, err := cmd.CombinedOutput()
-	prettyOutput := strings.Replace(string(output), "\n", "", -1)
+func run(ctx context.Context, command string) (string, error) {
+	output, err := runner.Run(ctx, runner.Command{Name: "osascript", Args: []string{"-e", command}, Timeout: osascriptTimeout})
+	prettyOutput := strings.Replace(output, "\n", "", -1)
 
 	// Ignore errors from the user hitting the cancel button
-	if err != nil && strings.Index(string(output), "User canceled.") < 0 {
-		return "", errors.New(err.Error() + ": " + prettyOutput + " (" + command + ")")
+	var rerr *runner.Error
+	if errors.As(err, &rerr) && strings.Contains(rerr.Stderr, "User canceled.") {
+		return prettyOutput, nil
+	}
+	if err != nil {
+		return "", err
 	}
 
 	return prettyOutput, nil
@@ -1,6 +1,11 @@
 package desktop
 
-import "testing"
+import (
+	"context"
+	"testing"
+
+	"github.com/blacktop/lporg/internal/runner/runnertest"
+)
 
 func TestSetDesktopImage(t *testing.T) {
 	type args struct {
@@ -9,6 +14,7 @@ func TestSetDesktopImage(t *testing.T) {
 	tests := []struct {
 		name    string
 		args    args
+		result  runnertest.Result
 		want    string
 		wantErr bool
 	}{
@@ -18,10 +24,24 @@ func TestSetDesktopImage(t *testing.T) {
 			want:    "",
 			wantErr: false,
 		},
+		{
+			name:    "user canceled",
+			args:    args{image: "/Users/blacktop/Pictures/bg.jpg"},
+			result:  runnertest.Result{Stderr: "execution error: User canceled. (-128)\n"},
+			want:    "",
+			wantErr: false,
+		},
+		{
+			name:    "finder error",
+			args:    args{image: "/Users/blacktop/Pictures/missing.jpg"},
+			result:  runnertest.Result{Stderr: "execution error: Finder got an error: Can’t set desktop picture. (-10006)\n"},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := SetDesktopImage(tt.args.image)
+			fake := runnertest.Install(t).On("osascript", tt.result)
+			got, err := SetDesktopImage(context.Background(), tt.args.image)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("SetDesktopImage() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -29,6 +49,10 @@ func TestSetDesktopImage(t *testing.T) {
 			if got != tt.want {
 				t.Errorf("SetDesktopImage() = %v, want %v", got, tt.want)
 			}
+			calls := fake.Calls()
+			if len(calls) != 1 || calls[0].Name != "osascript" || calls[0].Args[1] != buildTell("Finder", "set desktop picture to POSIX file "+wrapInQuotes(tt.args.image)) {
+				t.Errorf("SetDesktopImage() ran %q", fake.Commands())
+			}
 		})
 	}
 }
@@ -10,9 +10,11 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/apex/log"
 	"github.com/blacktop/lporg/internal/database"
+	"github.com/blacktop/lporg/internal/runner"
 	"github.com/blacktop/lporg/internal/utils"
 	"howett.net/plist"
 )
@@ -21,6 +23,11 @@ const (
 	dockPlistPath       = "/Library/Preferences/com.apple.dock.plist"
 	dockLaunchAgentID   = "com.apple.Dock.agent"
 	dockLaunchAgentPath = "/System/Library/LaunchAgents/com.apple.Dock.agent.plist"
+
+	// launchctl can hang when launchd is busy
+	launchctlTimeout = 30 * time.Second
+	killallTimeout   = 10 * time.Second
+	defaultsTimeout  = 30 * time.Second
 )
 
 // Plist is a dock plist object
@@ -40,6 +47,8 @@ type Plist struct {
 	RecentApps                  []any    `plist:"recent-apps"`
 	Region                      string   `plist:"region"`
 	ShowRecents                 bool     `plist:"show-recents"`
+	SpringboardColumns          int      `plist:"springboard-columns,omitempty"`
+	SpringboardRows             int      `plist:"springboard-rows,omitempty"`
 	ShowAppExposeGestureEnabled bool     `plist:"showAppExposeGestureEnabled"`
 	TileSize                    any      `plist:"tilesize,omitempty"`
 	TrashFull                   bool     `plist:"trash-full"`
@@ -225,42 +234,34 @@ func (p *Plist) AddOther(other database.Folder) error {
 
 // ApplySettings applies the dock settings to the plist
 func (p *Plist) ApplySettings(setting database.DockSettings) error {
+	if err := setting.Verify(); err != nil {
+		return err
+	}
 	p.AutoHide = setting.AutoHide
 	p.Magnification = setting.Magnification
 	p.MinimizeToApplication = setting.MinimizeToApplication
 	p.MruSpaces = setting.MruSpaces
 	p.ShowRecents = setting.ShowRecents
-	switch v := setting.LargeSize.(type) {
-	case float64:
-		if v < 16 && v > 128 {
-			return fmt.Errorf("large size must be between 16 and 128: %d", setting.LargeSize)
-		}
-	case int:
-		if v < 16 && v > 128 {
-			return fmt.Errorf("large size must be between 16 and 128: %d", setting.LargeSize)
-		}
+	return nil
+}
+
+// Save saves the dock plist from struct and restarts the Dock
+func (p *Plist) Save(ctx context.Context) error {
+	if err := p.unload(ctx); err != nil {
+		return fmt.Errorf("dock save: %w", err)
 	}
-	switch v := setting.TileSize.(type) {
-	case float64:
-		if v < 16 && v > 128 {
-			return fmt.Errorf("tile size must be between 16 and 128: %d", setting.TileSize)
-		}
-	case int:
-		if v < 16 && v > 128 {
-			return fmt.Errorf("tile size must be between 16 and 128: %d", setting.TileSize)
-		}
+	if err := p.Import(ctx); err != nil {
+		return fmt.Errorf("dock save: %w", err)
 	}
-	return nil
+	return p.restart(ctx)
 }
 
-// Save saves the dock plist from struct
-func (p *Plist) Save() error {
+// Import backs up the users dock plist and imports the plist from struct
+// into the com.apple.dock defaults domain without restarting the Dock
+func (p *Plist) Import(ctx context.Context) error {
 
 	p.ModCount++
 
-	if err := p.unload(); err != nil {
-		return fmt.Errorf("dock save: %w", err)
-	}
 	// backup previous plist
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -293,52 +294,85 @@ func (p *Plist) Save() error {
 	}
 	tmp.Close()
 
-	// import plist and restart dock
-	if err := p.importPlist(tmp.Name()); err != nil {
+	// import plist
+	if err := p.importPlist(ctx, tmp.Name()); err != nil {
 		return fmt.Errorf("failed to import plist: %w", err)
 	}
-	return p.restart()
+	return nil
 }
 
-func (p *Plist) importPlist(path string) error {
+// SaveAs writes the dock plist from struct to path without touching the running Dock
+func (p *Plist) SaveAs(path string) error {
+
+	p.ModCount++
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create plist '%s': %w", path, err)
+	}
+	defer f.Close()
+
+	utils.Indent(log.WithField("plist", path).Info, 3)("writing dock plist")
+	if err := plist.NewBinaryEncoder(f).Encode(p); err != nil {
+		return fmt.Errorf("failed to encode plist: %w", err)
+	}
+	return nil
+}
+
+func (p *Plist) importPlist(ctx context.Context, path string) error {
+	return ImportFile(ctx, path)
+}
+
+// ImportFile imports the plist file at path into the com.apple.dock defaults domain
+func ImportFile(ctx context.Context, path string) error {
 	utils.Indent(log.Info, 3)("importing dock plist")
-	if _, err := utils.RunCommand(context.Background(), "/usr/bin/defaults", "import", "com.apple.dock", path); err != nil {
-		return fmt.Errorf("failed to defaults import dock plist '%s': %v", path, err)
+	if _, err := runner.Run(ctx, runner.Command{Name: "/usr/bin/defaults", Args: []string{"import", "com.apple.dock", path}, Timeout: defaultsTimeout}); err != nil {
+		return fmt.Errorf("failed to defaults import dock plist '%s': %w", path, err)
 	}
 	return nil
 }
 
-func (p *Plist) kickstart() error {
+func (p *Plist) kickstart(ctx context.Context) error {
 	utils.Indent(log.Info, 3)("restarting com.apple.Dock.agent service")
-	if _, err := utils.RunCommand(context.Background(), "/bin/launchctl", "kickstart", "-k", fmt.Sprintf("gui/%d/com.apple.Dock.agent", os.Getuid())); err != nil {
-		return fmt.Errorf("failed to kickstart dock: %v", err)
+	if _, err := runner.Run(ctx, runner.Command{Name: "/bin/launchctl", Args: []string{"kickstart", "-k", fmt.Sprintf("gui/%d/com.apple.Dock.agent", os.Getuid())}, Timeout: launchctlTimeout}); err != nil {
+		return fmt.Errorf("failed to kickstart dock: %w", err)
 	}
 	return nil
 }
 
-func (p *Plist) killall() error {
+func (p *Plist) killall(ctx context.Context) error {
 	utils.Indent(log.Info, 3)("killing Dock")
-	if _, err := utils.RunCommand(context.Background(), "/usr/bin/killall", "Dock"); err != nil {
-		return fmt.Errorf("failed to kill Dock: %v", err)
+	if _, err := runner.Run(ctx, runner.Command{Name: "/usr/bin/killall", Args: []string{"Dock"}, Timeout: killallTimeout}); err != nil {
+		return fmt.Errorf("failed to kill Dock: %w", err)
 	}
 	return nil
 }
 
-func (p *Plist) unload() error {
+func (p *Plist) unload(ctx context.Context) error {
+	return Stop(ctx)
+}
+
+func (p *Plist) restart(ctx context.Context) error {
+	return Start(ctx)
+}
+
+// Stop unloads the Dock launch agent so the Dock stays stopped until Start
+func Stop(ctx context.Context) error {
 	utils.Indent(log.Info, 3)("unloading Dock launch agent")
-	if _, err := utils.RunCommand(context.Background(), "/bin/launchctl", "unload", dockLaunchAgentPath); err != nil {
-		return fmt.Errorf("failed to unload Dock launch agent: %v", err)
+	if _, err := runner.Run(ctx, runner.Command{Name: "/bin/launchctl", Args: []string{"unload", dockLaunchAgentPath}, Timeout: launchctlTimeout}); err != nil {
+		return fmt.Errorf("failed to unload Dock launch agent: %w", err)
 	}
 	return nil
 }
 
-func (p *Plist) restart() error {
+// Start loads and starts the Dock launch agent
+func Start(ctx context.Context) error {
 	utils.Indent(log.Info, 3)("restart Dock launch agent")
-	if _, err := utils.RunCommand(context.Background(), "/bin/launchctl", "load", dockLaunchAgentPath); err != nil {
-		return fmt.Errorf("failed to load Dock launch agent: %v", err)
+	if _, err := runner.Run(ctx, runner.Command{Name: "/bin/launchctl", Args: []string{"load", dockLaunchAgentPath}, Timeout: launchctlTimeout}); err != nil {
+		return fmt.Errorf("failed to load Dock launch agent: %w", err)
 	}
-	if _, err := utils.RunCommand(context.Background(), "/bin/launchctl", "start", dockLaunchAgentID); err != nil {
-		return fmt.Errorf("failed to start Dock launch agent: %v", err)
+	if _, err := runner.Run(ctx, runner.Command{Name: "/bin/launchctl", Args: []string{"start", dockLaunchAgentID}, Timeout: launchctlTimeout}); err != nil {
+		return fmt.Errorf("failed to start Dock launch agent: %w", err)
 	}
 	return nil
 }
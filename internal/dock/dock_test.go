@@ -2,8 +2,11 @@
 package dock
 
 import (
+	"context"
 	"reflect"
 	"testing"
+
+	"github.com/blacktop/lporg/internal/runner/runnertest"
 )
 
 func TestLoadDockPlist(t *testing.T) {
@@ -38,3 +41,73 @@ func TestLoadDockPlist(t *testing.T) {
 		})
 	}
 }
+
+func TestStopStart(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name    string
+		run     func(context.Context) error
+		ctx     context.Context
+		fail    string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "stop",
+			run:  Stop,
+			want: []string{"/bin/launchctl unload " + dockLaunchAgentPath},
+		},
+		{
+			name: "start",
+			run:  Start,
+			want: []string{
+				"/bin/launchctl load " + dockLaunchAgentPath,
+				"/bin/launchctl start " + dockLaunchAgentID,
+			},
+		},
+		{
+			name:    "start load fails",
+			run:     Start,
+			fail:    "/bin/launchctl load " + dockLaunchAgentPath,
+			want:    []string{"/bin/launchctl load " + dockLaunchAgentPath},
+			wantErr: "failed to load Dock launch agent: command '/bin/launchctl load " + dockLaunchAgentPath + "' failed: exit status 1: Load failed: 5: Input/output error",
+		},
+		{
+			name:    "stop interrupted",
+			run:     Stop,
+			ctx:     cancelled,
+			want:    []string{"/bin/launchctl unload " + dockLaunchAgentPath},
+			wantErr: "failed to unload Dock launch agent: command '/bin/launchctl unload " + dockLaunchAgentPath + "' failed: context canceled",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := runnertest.Install(t)
+			if len(tt.fail) > 0 {
+				fake.On(tt.fail, runnertest.Result{Stderr: "Load failed: 5: Input/output error\n"})
+			}
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			err := tt.run(ctx)
+			if len(tt.wantErr) > 0 {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("error = %v, want %s", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Errorf("error = %v", err)
+			}
+			if got := fake.Commands(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ran %q, want %q", got, tt.want)
+			}
+			for _, cmd := range fake.Calls() {
+				if cmd.Timeout != launchctlTimeout {
+					t.Errorf("%s timeout = %s, want %s", cmd, cmd.Timeout, launchctlTimeout)
+				}
+			}
+		})
+	}
+}
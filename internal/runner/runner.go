@@ -0,0 +1,103 @@
+// Package runner runs the external commands lporg uses to talk to macOS.
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout is how long a command may run if it does not set a timeout
+const DefaultTimeout = 30 * time.Second
+
+// Command is an external command and how long it may run
+type Command struct {
+	Name    string
+	Args    []string
+	Timeout time.Duration // 0 for DefaultTimeout
+}
+
+// String returns the command line
+func (c Command) String() string {
+	return strings.Join(append([]string{c.Name}, c.Args...), " ")
+}
+
+// Runner runs commands
+type Runner interface {
+	// Run runs the command and returns its stdout
+	Run(ctx context.Context, cmd Command) (string, error)
+}
+
+// Error is returned when a command fails, times out or cannot be started
+type Error struct {
+	Command  Command
+	ExitCode int // -1 if the command did not exit by itself
+	Stderr   string
+	Err      error
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("command '%s' failed: %v", e.Command, e.Err)
+	if errors.Is(e.Err, context.DeadlineExceeded) {
+		msg = fmt.Sprintf("command '%s' timed out", e.Command)
+	}
+	if stderr := strings.TrimSpace(e.Stderr); len(stderr) > 0 {
+		msg += ": " + stderr
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Exec runs commands with os/exec
+type Exec struct{}
+
+// Run runs the command, killing it if it runs longer than its timeout or ctx is done
+func (Exec) Run(ctx context.Context, cmd Command) (string, error) {
+	timeout := cmd.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	c := exec.CommandContext(ctx, cmd.Name, cmd.Args...)
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+	c.WaitDelay = time.Second // don't wait on children still holding stdout after a kill
+	if err := c.Run(); err != nil {
+		code := -1
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		} else if exitErr, ok := err.(*exec.ExitError); ok {
+			code = exitErr.ExitCode()
+		}
+		return stdout.String(), &Error{Command: cmd, ExitCode: code, Stderr: stderr.String(), Err: err}
+	}
+	return stdout.String(), nil
+}
+
+// ExitCode returns the exit code of the command that failed with err, or -1 if it did
+// not run or exit by itself
+func ExitCode(err error) int {
+	var rerr *Error
+	if errors.As(err, &rerr) {
+		return rerr.ExitCode
+	}
+	return -1
+}
+
+// Default is the runner lporg uses. Tests replace it with a runnertest.Fake.
+var Default Runner = Exec{}
+
+// Run runs the command with the default runner
+func Run(ctx context.Context, cmd Command) (string, error) {
+	return Default.Run(ctx, cmd)
+}
@@ -0,0 +1,77 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExec_Run(t *testing.T) {
+	tests := []struct {
+		name        string
+		cmd         Command
+		want        string
+		wantErr     string
+		wantCode    int
+		wantTimeout bool
+	}{
+		{
+			name: "stdout",
+			cmd:  Command{Name: "sh", Args: []string{"-c", "echo hello; echo ignored >&2"}},
+			want: "hello\n",
+		},
+		{
+			name:     "stderr in error",
+			cmd:      Command{Name: "sh", Args: []string{"-c", "echo partial; echo no such agent >&2; exit 3"}},
+			want:     "partial\n",
+			wantErr:  "command 'sh -c echo partial; echo no such agent >&2; exit 3' failed: exit status 3: no such agent",
+			wantCode: 3,
+		},
+		{
+			name:        "timeout",
+			cmd:         Command{Name: "sleep", Args: []string{"10"}, Timeout: 50 * time.Millisecond},
+			wantErr:     "command 'sleep 10' timed out",
+			wantCode:    -1,
+			wantTimeout: true,
+		},
+		{
+			name:     "not found",
+			cmd:      Command{Name: "lporg-no-such-command"},
+			wantErr:  "command 'lporg-no-such-command' failed: exec: \"lporg-no-such-command\": executable file not found in $PATH",
+			wantCode: -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			got, err := Exec{}.Run(context.Background(), tt.cmd)
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("Run() error = %v", err)
+				}
+			} else {
+				var rerr *Error
+				if !errors.As(err, &rerr) {
+					t.Fatalf("Run() error = %v, want *Error", err)
+				}
+				if !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Errorf("Run() error = %q, want %q", err, tt.wantErr)
+				}
+			}
+			if got != tt.want {
+				t.Errorf("Run() = %q, want %q", got, tt.want)
+			}
+			if code := ExitCode(err); err != nil && code != tt.wantCode {
+				t.Errorf("ExitCode() = %d, want %d", code, tt.wantCode)
+			}
+			if errors.Is(err, context.DeadlineExceeded) != tt.wantTimeout {
+				t.Errorf("Run() timed out = %v, want %v", !tt.wantTimeout, tt.wantTimeout)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("Run() took %s", elapsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+// Package runnertest provides a fake runner that records commands instead of running them.
+package runnertest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/blacktop/lporg/internal/runner"
+)
+
+// Result is what a faked command returns
+type Result struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int   // non-zero fails the command
+	Err      error // wrapped in a runner.Error like a failed command
+}
+
+// Fake records the commands it is asked to run and returns canned results
+type Fake struct {
+	mu      sync.Mutex
+	calls   []runner.Command
+	results map[string][]Result
+}
+
+// New returns a fake where every command succeeds with no output
+func New() *Fake {
+	return &Fake{results: make(map[string][]Result)}
+}
+
+// Install replaces runner.Default with a new fake until the test ends
+func Install(tb testing.TB) *Fake {
+	tb.Helper()
+	f := New()
+	orig := runner.Default
+	runner.Default = f
+	tb.Cleanup(func() { runner.Default = orig })
+	return f
+}
+
+// On sets the results of a command line, e.g. "killall Dock", or of every command with a
+// name. Each run returns the next result and the last one is repeated.
+func (f *Fake) On(command string, results ...Result) *Fake {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.results[command] = results
+	return f
+}
+
+// next pops the next result for key
+func (f *Fake) next(key string) (Result, bool) {
+	results := f.results[key]
+	if len(results) == 0 {
+		return Result{}, false
+	}
+	if len(results) > 1 {
+		f.results[key] = results[1:]
+	}
+	return results[0], true
+}
+
+// Run records the command and returns its result
+func (f *Fake) Run(ctx context.Context, cmd runner.Command) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, cmd)
+
+	if err := ctx.Err(); err != nil {
+		return "", &runner.Error{Command: cmd, Err: err}
+	}
+	result, ok := f.next(cmd.String())
+	if !ok {
+		result, _ = f.next(cmd.Name)
+	}
+	if result.ExitCode == 0 && (result.Err != nil || len(result.Stderr) > 0) {
+		result.ExitCode = 1
+	}
+	if result.Err == nil && result.ExitCode != 0 {
+		result.Err = fmt.Errorf("exit status %d", result.ExitCode)
+	}
+	if result.Err != nil {
+		return result.Stdout, &runner.Error{Command: cmd, ExitCode: result.ExitCode, Stderr: result.Stderr, Err: result.Err}
+	}
+	return result.Stdout, nil
+}
+
+// Calls returns the commands run so far
+func (f *Fake) Calls() []runner.Command {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]runner.Command(nil), f.calls...)
+}
+
+// Commands returns the command lines run so far
+func (f *Fake) Commands() []string {
+	var commands []string
+	for _, cmd := range f.Calls() {
+		commands = append(commands, cmd.String())
+	}
+	return commands
+}